@@ -0,0 +1,59 @@
+package heat
+
+import (
+	"unsafe"
+)
+
+// The arena type is a reusable buffer backing all strings of a parsed
+// message header, which lets ReadRequestHeaderInto and ReadResponseHeaderInto
+// avoid allocating a string per field value.
+//
+// Copying a Request or Response copies its arena too, so the copy's strings
+// alias the original's buffer. To keep a copy from overwriting the original's
+// strings when it is reused, an arena remembers its own address, and
+// recognizes copies of itself by their different address.
+type arena struct {
+	buf   []byte
+	owner *arena
+}
+
+// string returns b as a string. Strings returned by a non-nil arena alias
+// its buffer, and are only valid until the arena is reset. A nil arena
+// simply allocates a new string.
+func (a *arena) string(b []byte) string {
+	if a == nil {
+		return string(b)
+	} else if len(b) == 0 {
+		return ""
+	}
+
+	n := len(a.buf)
+	a.buf = append(a.buf, b...)
+
+	return unsafe.String(&a.buf[n], len(b))
+}
+
+// stringify works like the package-level stringify function, but falls back
+// to a.string for uncommon strings.
+func (a *arena) stringify(b []byte) string {
+	if a == nil {
+		return stringify(b)
+	} else if s, ok := common[string(b)]; ok {
+		return s
+	}
+	return a.string(b)
+}
+
+// reset prepares the arena for reuse, returning false if it was copied from
+// another (in which case its buffer is dropped rather than overwritten, and
+// the same should be done with any other buffers shared with the original).
+func (a *arena) reset() bool {
+	if a.owner != a {
+		a.buf = nil
+		a.owner = a
+		return false
+	}
+
+	a.buf = a.buf[:0]
+	return true
+}
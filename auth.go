@@ -0,0 +1,352 @@
+package heat
+
+import (
+	"encoding/base64"
+	"strings"
+)
+
+// The Credentials struct represents the value of an "Authorization" or
+// "Proxy-Authorization" field, as described in RFC 7235 section 2.1.
+// Depending on the scheme, credentials carry either a single token68 value
+// (e.g. "Basic" and "Bearer") or a list of parameters (e.g. "Digest").
+type Credentials struct {
+	Scheme  string
+	Token68 string
+	Params  Fields
+}
+
+// The Challenge struct represents a single challenge from
+// a "WWW-Authenticate" or "Proxy-Authenticate" field.
+type Challenge struct {
+	Scheme  string
+	Token68 string
+	Params  Fields
+}
+
+// ParseCredentials parses the value of an "Authorization" or
+// "Proxy-Authorization" field.
+func ParseCredentials(s string) (*Credentials, error) {
+	list, err := parseAuth(s)
+	if err != nil {
+		return nil, err
+	} else if len(list) != 1 {
+		return nil, ErrInvalidAuth
+	}
+
+	return &Credentials{list[0].Scheme, list[0].Token68, list[0].Params}, nil
+}
+
+// NewBasicCredentials returns credentials for the "Basic" scheme, as
+// described in RFC 7617.
+func NewBasicCredentials(username, password string) *Credentials {
+	return &Credentials{
+		Scheme:  "Basic",
+		Token68: base64.StdEncoding.EncodeToString([]byte(username + ":" + password)),
+	}
+}
+
+// NewBearerCredentials returns credentials for the "Bearer" scheme, as
+// described in RFC 6750. ErrInvalidAuth is returned if token isn't a valid
+// token68 value.
+func NewBearerCredentials(token string) (*Credentials, error) {
+	if !isToken68(token) {
+		return nil, ErrInvalidAuth
+	}
+
+	return &Credentials{
+		Scheme:  "Bearer",
+		Token68: token,
+	}, nil
+}
+
+// Basic decodes the username and password of credentials using the "Basic"
+// scheme. The last return value indicates whether decoding was successful.
+func (c *Credentials) Basic() (username, password string, ok bool) {
+	if !strcaseeq(c.Scheme, "Basic") {
+		return "", "", false
+	}
+
+	raw, err := base64.StdEncoding.DecodeString(c.Token68)
+	if err != nil {
+		return "", "", false
+	}
+
+	s := string(raw)
+
+	i := strings.IndexByte(s, ':')
+	if i < 0 {
+		return "", "", false
+	}
+
+	return s[:i], s[i+1:], true
+}
+
+// Bearer returns the token of credentials using the "Bearer" scheme.
+func (c *Credentials) Bearer() (token string, ok bool) {
+	if !strcaseeq(c.Scheme, "Bearer") || c.Token68 == "" {
+		return "", false
+	}
+	return c.Token68, true
+}
+
+// String serializes the credentials for use as the value of an
+// "Authorization" or "Proxy-Authorization" field. A Token68 value containing
+// characters not allowed by RFC 7235 is left out.
+func (c *Credentials) String() string {
+	return formatAuth(c.Scheme, c.Token68, c.Params, credentialsTokenParams)
+}
+
+// ParseChallenges parses the value of a "WWW-Authenticate" or
+// "Proxy-Authenticate" field, which may contain any number of
+// comma-separated challenges.
+func ParseChallenges(s string) ([]*Challenge, error) {
+	return parseAuth(s)
+}
+
+// String serializes the challenge for use as the value of
+// a "WWW-Authenticate" or "Proxy-Authenticate" field.
+func (c *Challenge) String() string {
+	return formatAuth(c.Scheme, c.Token68, c.Params, challengeTokenParams)
+}
+
+// Challenges parses all challenges from the list's fields with the specified
+// name, typically "WWW-Authenticate" or "Proxy-Authenticate".
+func (fs *Fields) Challenges(name string) ([]*Challenge, error) {
+	var challenges []*Challenge
+
+	for _, f := range *fs {
+		if !f.Is(name) {
+			continue
+		}
+
+		list, err := parseAuth(f.Value)
+		if err != nil {
+			return nil, err
+		}
+
+		challenges = append(challenges, list...)
+	}
+
+	return challenges, nil
+}
+
+// Parameters which are conventionally sent as tokens rather than
+// quoted-strings. All other parameter values are quoted when serialized, as
+// RFC 7235 and RFC 7616 require for e.g. "realm" and "nonce".
+var credentialsTokenParams = map[string]bool{
+	"algorithm": true,
+	"qop":       true,
+	"nc":        true,
+	"userhash":  true,
+}
+
+var challengeTokenParams = map[string]bool{
+	"algorithm": true,
+	"stale":     true,
+	"charset":   true,
+	"userhash":  true,
+}
+
+func formatAuth(scheme, token68 string, params Fields, tokens map[string]bool) string {
+	var b []byte
+
+	b = append(b, scheme...)
+
+	if token68 != "" {
+		if isToken68(token68) {
+			b = append(b, ' ')
+			b = append(b, token68...)
+		}
+		return string(b)
+	}
+
+	for i, p := range params {
+		if i == 0 {
+			b = append(b, ' ')
+		} else {
+			b = append(b, ", "...)
+		}
+
+		b = append(b, p.Name...)
+		b = append(b, '=')
+
+		if tokens[strings.ToLower(p.Name)] && p.Value != "" && isToken(p.Value) {
+			b = append(b, p.Value...)
+		} else {
+			b = append(b, quote(p.Value)...)
+		}
+	}
+
+	return string(b)
+}
+
+// parseAuth parses a comma-separated list of challenges (or, equivalently,
+// credentials), as defined in RFC 7235 section 2.1:
+//
+//	challenge   = auth-scheme [ 1*SP ( token68 / #auth-param ) ]
+//	auth-param  = token BWS "=" BWS ( token / quoted-string )
+//	token68     = 1*( ALPHA / DIGIT / "-" / "." / "_" / "~" / "+" / "/" ) *"="
+//
+// Because auth-params and challenges share the same list separator, a list
+// element starting with a token followed by "=" is considered a parameter of
+// the previous challenge.
+func parseAuth(s string) ([]*Challenge, error) {
+	var list []*Challenge
+	var p = authParser{s, 0}
+
+	for {
+		p.skip(" \t,")
+		if p.done() {
+			break
+		}
+
+		scheme := p.token()
+		if scheme == "" {
+			return nil, ErrInvalidAuth
+		}
+
+		c := &Challenge{Scheme: scheme}
+		list = append(list, c)
+
+		p.skip(" \t")
+		if p.done() || p.peek() == ',' {
+			continue
+		}
+
+		// Try parsing a token68 value first.
+		start := p.i
+		t68 := p.token68()
+		p.skip(" \t")
+
+		if t68 != "" && (p.done() || p.peek() == ',') {
+			c.Token68 = t68
+			continue
+		}
+
+		p.i = start
+
+		// Parse a list of auth-params.
+		for {
+			name := p.token()
+			if name == "" {
+				return nil, ErrInvalidAuth
+			}
+
+			p.skip(" \t")
+			if p.done() || p.peek() != '=' {
+				return nil, ErrInvalidAuth
+			}
+			p.i++
+			p.skip(" \t")
+
+			var value string
+			var ok bool
+
+			if !p.done() && p.peek() == '"' {
+				if value, ok = p.quoted(); !ok {
+					return nil, ErrInvalidAuth
+				}
+			} else {
+				value = p.token()
+			}
+
+			c.Params = append(c.Params, Field{name, value})
+
+			p.skip(" \t")
+			if p.done() {
+				break
+			}
+			if p.peek() != ',' {
+				return nil, ErrInvalidAuth
+			}
+
+			// Look ahead to determine whether the next list element is
+			// another parameter or a new challenge.
+			start := p.i
+			p.skip(" \t,")
+			p.token()
+			p.skip(" \t")
+
+			isParam := !p.done() && p.peek() == '='
+			p.i = start
+			p.skip(" \t,")
+
+			if !isParam {
+				break
+			}
+		}
+	}
+
+	return list, nil
+}
+
+// isToken68 reports whether s is a valid token68 value.
+func isToken68(s string) bool {
+	p := authParser{s, 0}
+	return p.token68() != "" && p.done()
+}
+
+type authParser struct {
+	s string
+	i int
+}
+
+func (p *authParser) done() bool {
+	return p.i >= len(p.s)
+}
+
+func (p *authParser) peek() byte {
+	return p.s[p.i]
+}
+
+func (p *authParser) skip(set string) {
+	for p.i < len(p.s) && strings.IndexByte(set, p.s[p.i]) >= 0 {
+		p.i++
+	}
+}
+
+func (p *authParser) token() string {
+	start := p.i
+	for p.i < len(p.s) && istoken(p.s[p.i]) {
+		p.i++
+	}
+	return p.s[start:p.i]
+}
+
+func (p *authParser) token68() string {
+	start := p.i
+
+	for p.i < len(p.s) {
+		c := p.s[p.i]
+		if !('a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' || strings.IndexByte("-._~+/", c) >= 0) {
+			break
+		}
+		p.i++
+	}
+
+	if p.i == start {
+		return ""
+	}
+
+	for p.i < len(p.s) && p.s[p.i] == '=' {
+		p.i++
+	}
+
+	return p.s[start:p.i]
+}
+
+func (p *authParser) quoted() (string, bool) {
+	start := p.i
+
+	for p.i++; p.i < len(p.s); p.i++ {
+		switch p.s[p.i] {
+		case '\\':
+			p.i++
+		case '"':
+			p.i++
+			return unquote(p.s[start:p.i]), true
+		}
+	}
+
+	return "", false
+}
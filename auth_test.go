@@ -0,0 +1,102 @@
+package heat
+
+import (
+	"reflect"
+	"testing"
+)
+
+var parseChallengesTests = []struct {
+	in  string
+	out []*Challenge
+}{
+	{
+		`Basic realm="simple"`,
+		[]*Challenge{
+			{Scheme: "Basic", Params: Fields{{"realm", "simple"}}},
+		},
+	},
+	{
+		`Newauth realm="apps", type=1, title="Login to \"apps\"", Basic realm="simple"`,
+		[]*Challenge{
+			{Scheme: "Newauth", Params: Fields{{"realm", "apps"}, {"type", "1"}, {"title", `Login to "apps"`}}},
+			{Scheme: "Basic", Params: Fields{{"realm", "simple"}}},
+		},
+	},
+	{
+		`Negotiate, Negotiate abc/+def==, Bearer realm = "x"  ,, error="invalid_token"`,
+		[]*Challenge{
+			{Scheme: "Negotiate"},
+			{Scheme: "Negotiate", Token68: "abc/+def=="},
+			{Scheme: "Bearer", Params: Fields{{"realm", "x"}, {"error", "invalid_token"}}},
+		},
+	},
+}
+
+func TestParseChallenges(t *testing.T) {
+	for _, test := range parseChallengesTests {
+		out, err := ParseChallenges(test.in)
+		if err != nil || !reflect.DeepEqual(out, test.out) {
+			t.Errorf("ParseChallenges(%q):", test.in)
+			t.Errorf("  got  %+v, %v", out, err)
+			t.Errorf("  want %+v, <nil>", test.out)
+		}
+	}
+
+	for _, in := range []string{`=x`, `Basic realm="x" y`, `Basic realm="x`, `Basic a=b c`} {
+		if _, err := ParseChallenges(in); err != ErrInvalidAuth {
+			t.Errorf("ParseChallenges(%q):", in)
+			t.Errorf("  got  %v", err)
+			t.Errorf("  want %v", ErrInvalidAuth)
+		}
+	}
+}
+
+func TestCredentials(t *testing.T) {
+	c, err := ParseCredentials("Basic QWxhZGRpbjpvcGVuIHNlc2FtZQ==")
+	if err != nil {
+		t.Fatalf("ParseCredentials: %v", err)
+	}
+
+	if user, pass, ok := c.Basic(); user != "Aladdin" || pass != "open sesame" || !ok {
+		t.Errorf("Basic(): got %q, %q, %v", user, pass, ok)
+	}
+
+	if s := NewBasicCredentials("Aladdin", "open sesame").String(); s != "Basic QWxhZGRpbjpvcGVuIHNlc2FtZQ==" {
+		t.Errorf("NewBasicCredentials: got %q", s)
+	}
+
+	if c, err := NewBearerCredentials("mF_9.B5f-4.1JqM"); err != nil || c.String() != "Bearer mF_9.B5f-4.1JqM" {
+		t.Errorf("NewBearerCredentials: got %+v, %v", c, err)
+	}
+
+	for _, token := range []string{"", "a b", "a\r\nX-Evil: 1", "a=b", "a,b"} {
+		if c, err := NewBearerCredentials(token); err != ErrInvalidAuth {
+			t.Errorf("NewBearerCredentials(%q):", token)
+			t.Errorf("  got  %+v, %v", c, err)
+			t.Errorf("  want <nil>, %v", ErrInvalidAuth)
+		}
+
+		c := &Credentials{Scheme: "Bearer", Token68: token}
+		if s := c.String(); s != "Bearer" {
+			t.Errorf("String() with Token68 %q: got %q, want %q", token, s, "Bearer")
+		}
+	}
+
+	digest := &Credentials{
+		Scheme: "Digest",
+		Params: Fields{{"username", "Mufasa"}, {"qop", "auth"}, {"nc", "00000001"}, {"algorithm", "MD5"}, {"opaque", ""}},
+	}
+
+	want := `Digest username="Mufasa", qop=auth, nc=00000001, algorithm=MD5, opaque=""`
+	if s := digest.String(); s != want {
+		t.Errorf("String():")
+		t.Errorf("  got  %q", s)
+		t.Errorf("  want %q", want)
+	}
+
+	if out, err := ParseCredentials(want); err != nil || !reflect.DeepEqual(out, digest) {
+		t.Errorf("ParseCredentials(%q):", want)
+		t.Errorf("  got  %+v, %v", out, err)
+		t.Errorf("  want %+v, <nil>", digest)
+	}
+}
@@ -0,0 +1,90 @@
+package heat
+
+import (
+	"bufio"
+
+	"github.com/erkl/xo"
+)
+
+// NewBufioReader adapts a bufio.Reader for use with functions like
+// ReadRequestHeader and OpenBody. Lines longer than the bufio.Reader's buffer
+// cannot be peeked, and will fail with bufio.ErrBufferFull.
+func NewBufioReader(br *bufio.Reader) xo.Reader {
+	return &bufioReader{br}
+}
+
+type bufioReader struct {
+	*bufio.Reader
+}
+
+// Peek returns at least n bytes, including everything already buffered.
+// This lets callers search buffered data without blocking for more.
+func (r *bufioReader) Peek(n int) ([]byte, error) {
+	if m := r.Buffered(); m > n {
+		n = m
+	}
+	return r.Reader.Peek(n)
+}
+
+func (r *bufioReader) Consume(n int) error {
+	_, err := r.Discard(n)
+	return err
+}
+
+// NewBufioWriter adapts a bufio.Writer for use with functions like
+// WriteRequestHeader and WriteBody. Space reserved by Reserve is taken from
+// the bufio.Writer's own buffer whenever possible, so that committing it
+// doesn't require an extra copy.
+func NewBufioWriter(bw *bufio.Writer) xo.Writer {
+	return &bufioWriter{w: bw}
+}
+
+type bufioWriter struct {
+	w *bufio.Writer
+
+	// Reserved space, and scratch space for reservations larger than the
+	// bufio.Writer's buffer.
+	res     []byte
+	scratch []byte
+}
+
+func (w *bufioWriter) Write(buf []byte) (int, error) {
+	return w.w.Write(buf)
+}
+
+func (w *bufioWriter) Reserve(n int) ([]byte, error) {
+	// Make room in the buffer if necessary.
+	if w.w.Available() < n && w.w.Buffered() > 0 {
+		if err := w.w.Flush(); err != nil {
+			return nil, err
+		}
+	}
+
+	if w.w.Available() >= n {
+		w.res = w.w.AvailableBuffer()[:n]
+	} else {
+		if cap(w.scratch) < n {
+			w.scratch = make([]byte, n)
+		}
+		w.res = w.scratch[:n]
+	}
+
+	return w.res, nil
+}
+
+func (w *bufioWriter) Commit(n int) error {
+	if n > len(w.res) {
+		return errInvalidCommit
+	}
+
+	// When the reserved space is part of the bufio.Writer's buffer, this
+	// Write call amounts to advancing its write position.
+	_, err := w.w.Write(w.res[:n])
+	w.res = nil
+
+	return err
+}
+
+func (w *bufioWriter) Flush() error {
+	return w.w.Flush()
+}
@@ -0,0 +1,69 @@
+package heat
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestBufioRoundTrip(t *testing.T) {
+	req := &Request{
+		Method: "POST",
+		URI:    "/upload",
+		Major:  1,
+		Minor:  1,
+		Fields: Fields{
+			{"Host", "example.com"},
+			{"Transfer-Encoding", "chunked"},
+			{"X-Padding", strings.Repeat("x", 100)},
+		},
+	}
+
+	var buf bytes.Buffer
+
+	// Use a tiny buffer to exercise reservations larger than it.
+	w := NewBufioWriter(bufio.NewWriterSize(&buf, 32))
+
+	if err := WriteRequestHeader(w, req); err != nil {
+		t.Fatalf("WriteRequestHeader: %v", err)
+	}
+	if err := WriteBody(w, strings.NewReader("hello, world"), Chunked); err != nil {
+		t.Fatalf("WriteBody: %v", err)
+	}
+	if err := w.Flush(); err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+
+	r := NewBufioReader(bufio.NewReader(&buf))
+
+	out, err := ReadRequestHeader(r)
+	if err != nil {
+		t.Fatalf("ReadRequestHeader: %v", err)
+	}
+
+	if !reflect.DeepEqual(out.Fields, req.Fields) || out.Method != req.Method || out.URI != req.URI {
+		t.Errorf("ReadRequestHeader:")
+		t.Errorf("  got  %+v", out)
+		t.Errorf("  want %+v", req)
+	}
+
+	size, err := RequestBodySize(out)
+	if err != nil {
+		t.Fatalf("RequestBodySize: %v", err)
+	}
+
+	body, err := OpenBody(r, size)
+	if err != nil {
+		t.Fatalf("OpenBody: %v", err)
+	}
+
+	data, err := io.ReadAll(body)
+	if err != nil || string(data) != "hello, world" {
+		t.Errorf("body:")
+		t.Errorf("  got  %q, %v", data, err)
+		t.Errorf("  want %q, %v", "hello, world", nil)
+	}
+}
@@ -0,0 +1,641 @@
+// Package cache implements a private or shared HTTP cache, as described in
+// RFC 9111, on top of any function capable of performing an HTTP round trip
+// with heat's Request and Response types.
+//
+// Only responses to GET requests are stored. Bodies are buffered in memory
+// before being stored.
+package cache
+
+import (
+	"bytes"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/erkl/heat"
+)
+
+// Overridable in tests.
+var now = time.Now
+
+// The Transport interface is implemented by anything capable of performing
+// a single HTTP round trip.
+type Transport interface {
+	RoundTrip(req *heat.Request) (*heat.Response, error)
+}
+
+// The TransportFunc type is an adapter allowing the use of an ordinary
+// function as a Transport.
+type TransportFunc func(req *heat.Request) (*heat.Response, error)
+
+// RoundTrip calls fn(req).
+func (fn TransportFunc) RoundTrip(req *heat.Request) (*heat.Response, error) {
+	return fn(req)
+}
+
+// The Cache type wraps a Transport, storing responses and serving them for
+// subsequent requests when allowed to. It implements the Transport interface
+// itself.
+type Cache struct {
+	Transport Transport
+	Storage   Storage
+
+	// Shared caches (e.g. proxies) honor the "s-maxage" directive and won't
+	// store responses marked "private", or responses to requests with an
+	// "Authorization" field unless explicitly allowed.
+	Shared bool
+
+	// Responses with bodies larger than MaxBodySize bytes are not stored.
+	// A value of zero means no limit.
+	MaxBodySize int64
+
+	// Serializes updates of stored variants, which read, modify and write
+	// back a key's entries.
+	storeMu sync.Mutex
+
+	// Keys currently being revalidated in the background.
+	mu      sync.Mutex
+	pending map[string]bool
+	wg      sync.WaitGroup
+}
+
+// New returns a private Cache.
+func New(transport Transport, storage Storage) *Cache {
+	return &Cache{
+		Transport: transport,
+		Storage:   storage,
+	}
+}
+
+// RoundTrip serves a request from the cache if possible, and otherwise
+// forwards it to the underlying Transport, possibly revalidating a stored
+// response in the process.
+//
+// Successful unsafe requests (e.g. POST) invalidate stored responses. If
+// that fails, the Storage's error is returned even though the request itself
+// was performed.
+func (c *Cache) RoundTrip(req *heat.Request) (*heat.Response, error) {
+	u, err := req.ResolveURL()
+	if err != nil {
+		return nil, err
+	}
+
+	if req.Method != "GET" {
+		resp, err := c.Transport.RoundTrip(req)
+
+		// RFC 9111 section 4.4: unsafe requests invalidate the stored
+		// responses of the target URI when successful.
+		if err == nil && !isSafe(req.Method) && 200 <= resp.Status && resp.Status <= 399 {
+			if err := c.Storage.Delete("GET " + u.String()); err != nil {
+				discard(resp.Body)
+				return nil, err
+			}
+		}
+
+		return resp, err
+	}
+
+	// We don't bother combining partial responses.
+	if req.Fields.Has("Range") {
+		return c.Transport.RoundTrip(req)
+	}
+
+	key := "GET " + u.String()
+	reqcc := heat.ParseCacheControl(req.Fields)
+
+	entries, err := c.Storage.Load(key)
+	if err != nil {
+		return nil, err
+	}
+
+	e := selectVariant(entries, req)
+	t := now()
+
+	if e != nil {
+		switch c.check(e, req, reqcc, t) {
+		case fresh:
+			return serve(req, e, t), nil
+		case staleWhileRevalidate:
+			c.revalidateInBackground(req, key, reqcc, e)
+			return serve(req, e, t), nil
+		}
+	}
+
+	// RFC 9111 section 5.2.1.7.
+	if reqcc.OnlyIfCached {
+		resp := heat.NewResponse(504, heat.ReasonPhrase(504))
+		resp.Fields.Add("Content-Length", "0")
+		return resp, nil
+	}
+
+	return c.forward(req, key, reqcc, e)
+}
+
+// forward sends a request to the underlying Transport. If e is non-nil the
+// request is made conditional, and e is refreshed on a 304 response.
+func (c *Cache) forward(req *heat.Request, key string, reqcc *heat.CacheControl, e *Entry) (*heat.Response, error) {
+	out := req
+	if e != nil {
+		out = conditional(req, e)
+	}
+
+	reqTime := now()
+
+	resp, err := c.Transport.RoundTrip(out)
+	if err != nil {
+		if e != nil && c.staleIfError(e, reqcc, reqTime) {
+			return serve(req, e, reqTime), nil
+		}
+		return nil, err
+	}
+
+	respTime := now()
+
+	if e != nil {
+		switch {
+		case resp.Status == 304:
+			discard(resp.Body)
+
+			e = refresh(e, resp, reqTime, respTime)
+			if err := c.store(key, req, e); err != nil {
+				return nil, err
+			}
+
+			return serve(req, e, respTime), nil
+
+		case resp.Status >= 500 && c.staleIfError(e, reqcc, respTime):
+			discard(resp.Body)
+			return serve(req, e, respTime), nil
+		}
+	}
+
+	if !c.storable(req, reqcc, resp) {
+		return resp, nil
+	}
+
+	body, ok, err := c.buffer(resp)
+	if err != nil || !ok {
+		return resp, err
+	}
+
+	ne := &Entry{
+		Status:       resp.Status,
+		Reason:       resp.Reason,
+		Major:        resp.Major,
+		Minor:        resp.Minor,
+		Fields:       append(heat.Fields(nil), resp.Fields...),
+		Body:         body,
+		Vary:         selectingFields(resp.Fields, req.Fields),
+		RequestTime:  reqTime,
+		ResponseTime: respTime,
+	}
+
+	if err := c.store(key, req, ne); err != nil {
+		return nil, err
+	}
+
+	// The client's own conditional fields were replaced in the forwarded
+	// request, so they have to be evaluated here.
+	if e != nil {
+		return serve(req, ne, respTime), nil
+	}
+
+	return resp, nil
+}
+
+// revalidateInBackground starts revalidating a stored response, unless the
+// same key is already being revalidated. The request is copied, as the
+// caller is free to reuse it once RoundTrip has returned.
+func (c *Cache) revalidateInBackground(req *heat.Request, key string, reqcc *heat.CacheControl, e *Entry) {
+	c.mu.Lock()
+	if c.pending[key] {
+		c.mu.Unlock()
+		return
+	}
+	if c.pending == nil {
+		c.pending = make(map[string]bool)
+	}
+	c.pending[key] = true
+	c.mu.Unlock()
+
+	clone := cloneRequest(req)
+
+	c.wg.Add(1)
+
+	go func() {
+		defer c.wg.Done()
+
+		if resp, err := c.forward(clone, key, reqcc, e); err == nil {
+			discard(resp.Body)
+		}
+
+		c.mu.Lock()
+		delete(c.pending, key)
+		c.mu.Unlock()
+	}()
+}
+
+// cloneRequest returns a deep copy of a request without its body. Strings
+// are copied too, since those of requests read with ReadRequestHeaderInto
+// share a buffer which is overwritten when the request is reused.
+func cloneRequest(req *heat.Request) *heat.Request {
+	clone := &heat.Request{
+		Method: strings.Clone(req.Method),
+		URI:    strings.Clone(req.URI),
+		Major:  req.Major,
+		Minor:  req.Minor,
+		Fields: make(heat.Fields, len(req.Fields)),
+		Scheme: strings.Clone(req.Scheme),
+		Remote: strings.Clone(req.Remote),
+	}
+
+	for i, f := range req.Fields {
+		clone.Fields[i] = heat.Field{Name: strings.Clone(f.Name), Value: strings.Clone(f.Value)}
+	}
+
+	return clone
+}
+
+type usability int
+
+const (
+	revalidate usability = iota
+	fresh
+	staleWhileRevalidate
+)
+
+// check decides whether a stored response can be served without
+// revalidation, following RFC 9111 sections 4.2 and 5.2.
+func (c *Cache) check(e *Entry, req *heat.Request, reqcc *heat.CacheControl, t time.Time) usability {
+	cc := heat.ParseCacheControl(e.Fields)
+
+	if reqcc.NoCache || cc.NoCache || pragmaNoCache(req) {
+		return revalidate
+	}
+
+	lifetime := freshnessLifetime(e, cc, c.Shared)
+	age := currentAge(e, t)
+
+	if reqcc.MaxAge >= 0 && age > seconds(reqcc.MaxAge) {
+		return revalidate
+	}
+
+	if reqcc.MinFresh >= 0 && lifetime-age < seconds(reqcc.MinFresh) {
+		return revalidate
+	}
+
+	if age < lifetime {
+		return fresh
+	}
+
+	// The response is stale. Check whether we're allowed to serve it
+	// anyway.
+	if cc.MustRevalidate || (c.Shared && (cc.ProxyRevalidate || cc.SMaxAge >= 0)) {
+		return revalidate
+	}
+
+	staleness := age - lifetime
+
+	if reqcc.MaxStale == heat.MaxStaleAny || (reqcc.MaxStale >= 0 && staleness <= seconds(reqcc.MaxStale)) {
+		return fresh
+	}
+
+	if cc.StaleWhileRevalidate >= 0 && staleness <= seconds(cc.StaleWhileRevalidate) {
+		return staleWhileRevalidate
+	}
+
+	return revalidate
+}
+
+// staleIfError reports whether a stale response may be served in place of an
+// error, as described in RFC 5861 section 4.
+func (c *Cache) staleIfError(e *Entry, reqcc *heat.CacheControl, t time.Time) bool {
+	cc := heat.ParseCacheControl(e.Fields)
+
+	if cc.MustRevalidate || (c.Shared && (cc.ProxyRevalidate || cc.SMaxAge >= 0)) {
+		return false
+	}
+
+	staleness := currentAge(e, t) - freshnessLifetime(e, cc, c.Shared)
+
+	return (cc.StaleIfError >= 0 && staleness <= seconds(cc.StaleIfError)) ||
+		(reqcc.StaleIfError >= 0 && staleness <= seconds(reqcc.StaleIfError))
+}
+
+// storable implements the rules of RFC 9111 section 3.
+func (c *Cache) storable(req *heat.Request, reqcc *heat.CacheControl, resp *heat.Response) bool {
+	if reqcc.NoStore {
+		return false
+	}
+
+	if !heuristicallyCacheable[resp.Status] && !understood[resp.Status] {
+		return false
+	}
+
+	cc := heat.ParseCacheControl(resp.Fields)
+
+	if cc.NoStore || (c.Shared && cc.Private) {
+		return false
+	}
+
+	if c.Shared && req.Fields.Has("Authorization") && !cc.MustRevalidate && !cc.Public && cc.SMaxAge < 0 {
+		return false
+	}
+
+	for _, name := range varyNames(resp.Fields) {
+		if name == "*" {
+			return false
+		}
+	}
+
+	return resp.Fields.Has("Expires") ||
+		cc.MaxAge >= 0 ||
+		(c.Shared && cc.SMaxAge >= 0) ||
+		(!c.Shared && cc.Private) ||
+		cc.Public ||
+		heuristicallyCacheable[resp.Status]
+}
+
+// buffer reads a response's body into memory. If the body turns out to be
+// larger than c.MaxBodySize, the response's body is restored and the second
+// return value will be false.
+func (c *Cache) buffer(resp *heat.Response) ([]byte, bool, error) {
+	if resp.Body == nil {
+		return nil, true, nil
+	}
+
+	var r io.Reader = resp.Body
+	if c.MaxBodySize > 0 {
+		r = io.LimitReader(r, c.MaxBodySize+1)
+	}
+
+	body, err := io.ReadAll(r)
+	if err != nil {
+		resp.Body.Close()
+		return nil, false, err
+	}
+
+	if c.MaxBodySize > 0 && int64(len(body)) > c.MaxBodySize {
+		resp.Body = &readCloser{io.MultiReader(bytes.NewReader(body), resp.Body), resp.Body}
+		return nil, false, nil
+	}
+
+	if err := resp.Body.Close(); err != nil {
+		return nil, false, err
+	}
+
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	return body, true, nil
+}
+
+// store adds a new entry to the storage, replacing any previously stored
+// variant selected by the same request. Concurrent calls are serialized so
+// that they don't drop each other's variants; this only holds for a single
+// Cache, not for several sharing a Storage.
+func (c *Cache) store(key string, req *heat.Request, e *Entry) error {
+	c.storeMu.Lock()
+	defer c.storeMu.Unlock()
+
+	entries, err := c.Storage.Load(key)
+	if err != nil {
+		return err
+	}
+
+	var w int
+
+	for _, old := range entries {
+		if !matches(old, req) {
+			entries[w] = old
+			w++
+		}
+	}
+
+	return c.Storage.Store(key, append(entries[:w], e))
+}
+
+// serve constructs a response from a stored entry, evaluating the request's
+// conditional fields against it.
+func serve(req *heat.Request, e *Entry, t time.Time) *heat.Response {
+	age := strconv.FormatInt(int64(currentAge(e, t)/time.Second), 10)
+
+	etag, _ := e.Fields.Get("ETag")
+	lastModified := time.Time{}
+	if v, ok := e.Fields.Get("Last-Modified"); ok {
+		lastModified, _ = heat.ParseHTTPDate(v)
+	}
+
+	switch heat.CheckPreconditions(req, etag, lastModified) {
+	case heat.PreconditionNotModified:
+		resp := heat.NewResponse(304, heat.ReasonPhrase(304))
+		for _, f := range e.Fields {
+			if notModifiedFields[strings.ToLower(f.Name)] {
+				resp.Fields = append(resp.Fields, f)
+			}
+		}
+		resp.Fields.Set("Age", age)
+		return resp
+
+	case heat.PreconditionFailed:
+		resp := heat.NewResponse(412, heat.ReasonPhrase(412))
+		resp.Fields.Add("Content-Length", "0")
+		return resp
+	}
+
+	resp := &heat.Response{
+		Status: e.Status,
+		Reason: e.Reason,
+		Major:  e.Major,
+		Minor:  e.Minor,
+		Fields: append(heat.Fields(nil), e.Fields...),
+		Body:   io.NopCloser(bytes.NewReader(e.Body)),
+	}
+
+	resp.Fields.Set("Age", age)
+	return resp
+}
+
+// Fields included in generated 304 responses, as listed in RFC 9110 section
+// 15.4.5.
+var notModifiedFields = map[string]bool{
+	"cache-control":    true,
+	"content-location": true,
+	"date":             true,
+	"etag":             true,
+	"expires":          true,
+	"vary":             true,
+}
+
+// Fields which must not be copied from a 304 response into a stored entry.
+var unmergeableFields = map[string]bool{
+	"connection":        true,
+	"content-length":    true,
+	"keep-alive":        true,
+	"proxy-connection":  true,
+	"te":                true,
+	"trailer":           true,
+	"transfer-encoding": true,
+	"upgrade":           true,
+}
+
+// conditional returns a copy of req which asks the origin to validate the
+// stored response e. Any conditional fields set by the client are dropped.
+func conditional(req *heat.Request, e *Entry) *heat.Request {
+	out := *req
+	out.Fields = append(heat.Fields(nil), req.Fields...)
+
+	for _, name := range []string{"If-Match", "If-None-Match", "If-Modified-Since", "If-Unmodified-Since", "If-Range"} {
+		out.Fields.Remove(name)
+	}
+
+	if v, ok := e.Fields.Get("ETag"); ok {
+		out.Fields.Add("If-None-Match", v)
+	}
+
+	if v, ok := e.Fields.Get("Last-Modified"); ok {
+		out.Fields.Add("If-Modified-Since", v)
+	}
+
+	return &out
+}
+
+// refresh returns a copy of e updated with the header fields of a 304
+// response, as described in RFC 9111 section 3.2.
+func refresh(e *Entry, resp *heat.Response, reqTime, respTime time.Time) *Entry {
+	ne := *e
+	ne.Fields = append(heat.Fields(nil), e.Fields...)
+	ne.RequestTime = reqTime
+	ne.ResponseTime = respTime
+
+	seen := make(map[string]bool)
+
+	for _, f := range resp.Fields {
+		name := strings.ToLower(f.Name)
+		if unmergeableFields[name] {
+			continue
+		}
+
+		// The first occurrence of each name replaces all stored fields with
+		// that name, while subsequent ones are appended.
+		if !seen[name] {
+			ne.Fields.Set(f.Name, f.Value)
+			seen[name] = true
+		} else {
+			ne.Fields.Add(f.Name, f.Value)
+		}
+	}
+
+	return &ne
+}
+
+// selectVariant returns the most recent entry matching req, or nil.
+func selectVariant(entries []*Entry, req *heat.Request) *Entry {
+	var best *Entry
+
+	for _, e := range entries {
+		if matches(e, req) && (best == nil || e.ResponseTime.After(best.ResponseTime)) {
+			best = e
+		}
+	}
+
+	return best
+}
+
+// matches reports whether the request fields nominated by the stored
+// response's "Vary" field match those of req.
+func matches(e *Entry, req *heat.Request) bool {
+	for _, name := range varyNames(e.Fields) {
+		if name == "*" {
+			return false
+		}
+
+		a, aok := joinFields(req.Fields, name)
+		b, bok := joinFields(e.Vary, name)
+
+		if aok != bok || a != b {
+			return false
+		}
+	}
+
+	return true
+}
+
+func selectingFields(resp, req heat.Fields) heat.Fields {
+	var fields heat.Fields
+
+	for _, name := range varyNames(resp) {
+		if v, ok := joinFields(req, name); ok {
+			fields.Add(name, v)
+		}
+	}
+
+	return fields
+}
+
+func varyNames(fields heat.Fields) []string {
+	var names []string
+
+	for _, f := range fields {
+		if !f.Is("Vary") {
+			continue
+		}
+
+		for _, name := range strings.Split(f.Value, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				names = append(names, name)
+			}
+		}
+	}
+
+	return names
+}
+
+// joinFields combines the values of all fields with a particular name into
+// a single, normalized value.
+func joinFields(fields heat.Fields, name string) (string, bool) {
+	var elems []string
+	var found bool
+
+	for _, f := range fields {
+		if f.Is(name) {
+			found = true
+			for _, s := range strings.Split(f.Value, ",") {
+				if s = strings.TrimSpace(s); s != "" {
+					elems = append(elems, s)
+				}
+			}
+		}
+	}
+
+	return strings.Join(elems, ", "), found
+}
+
+func pragmaNoCache(req *heat.Request) bool {
+	if req.Fields.Has("Cache-Control") {
+		return false
+	}
+
+	v, ok := req.Fields.Get("Pragma")
+	return ok && strings.Contains(strings.ToLower(v), "no-cache")
+}
+
+func isSafe(method string) bool {
+	switch method {
+	case "GET", "HEAD", "OPTIONS", "TRACE":
+		return true
+	}
+	return false
+}
+
+func discard(body io.ReadCloser) {
+	if body != nil {
+		io.Copy(io.Discard, body)
+		body.Close()
+	}
+}
+
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
@@ -0,0 +1,322 @@
+package cache
+
+import (
+	"io"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/erkl/heat"
+)
+
+type origin struct {
+	calls   int
+	last    *heat.Request
+	respond func(req *heat.Request) *heat.Response
+}
+
+func (o *origin) RoundTrip(req *heat.Request) (*heat.Response, error) {
+	o.calls++
+	o.last = req
+	return o.respond(req), nil
+}
+
+// response constructs a response with the specified status and body, and
+// header fields given as name/value pairs.
+func response(status int, body string, fields ...string) *heat.Response {
+	resp := heat.NewResponse(status, heat.ReasonPhrase(status))
+	resp.Fields.Add("Date", heat.FormatHTTPDate(now()))
+	for i := 0; i < len(fields); i += 2 {
+		resp.Fields.Add(fields[i], fields[i+1])
+	}
+	resp.Body = io.NopCloser(strings.NewReader(body))
+	return resp
+}
+
+func get(t *testing.T, c *Cache, fields ...string) (*heat.Response, string) {
+	u, _ := url.Parse("http://example.com/x")
+
+	req := heat.NewRequest("GET", u)
+	for i := 0; i < len(fields); i += 2 {
+		req.Fields.Add(fields[i], fields[i+1])
+	}
+
+	resp, err := c.RoundTrip(req)
+	if err != nil {
+		t.Fatalf("RoundTrip: %v", err)
+	}
+
+	var body []byte
+	if resp.Body != nil {
+		body, _ = io.ReadAll(resp.Body)
+	}
+
+	return resp, string(body)
+}
+
+func withClock(t0 time.Time) func(d time.Duration) {
+	t := t0
+	now = func() time.Time { return t }
+	return func(d time.Duration) { t = t.Add(d) }
+}
+
+func TestFreshHit(t *testing.T) {
+	advance := withClock(time.Date(2015, 1, 1, 0, 0, 0, 0, time.UTC))
+	defer func() { now = time.Now }()
+
+	o := &origin{respond: func(req *heat.Request) *heat.Response {
+		return response(200, "hello", "Cache-Control", "max-age=60")
+	}}
+
+	c := New(o, NewMemoryStorage())
+
+	get(t, c)
+	advance(30 * time.Second)
+
+	resp, body := get(t, c)
+	if o.calls != 1 || body != "hello" {
+		t.Fatalf("got %d calls, body %q; want 1 call, body %q", o.calls, body, "hello")
+	}
+	if age, _ := resp.Fields.Get("Age"); age != "30" {
+		t.Errorf("got Age %q, want %q", age, "30")
+	}
+
+	advance(31 * time.Second)
+
+	if get(t, c); o.calls != 2 {
+		t.Errorf("got %d calls after expiry, want 2", o.calls)
+	}
+}
+
+func TestRevalidation(t *testing.T) {
+	advance := withClock(time.Date(2015, 1, 1, 0, 0, 0, 0, time.UTC))
+	defer func() { now = time.Now }()
+
+	o := &origin{respond: func(req *heat.Request) *heat.Response {
+		if v, _ := req.Fields.Get("If-None-Match"); v == `"v1"` {
+			return response(304, "", "Cache-Control", "max-age=10", "X-Version", "2")
+		}
+		return response(200, "hello", "ETag", `"v1"`, "Cache-Control", "no-cache", "X-Version", "1")
+	}}
+
+	c := New(o, NewMemoryStorage())
+
+	get(t, c)
+	advance(time.Second)
+
+	resp, body := get(t, c)
+	if o.calls != 2 || resp.Status != 200 || body != "hello" {
+		t.Fatalf("got %d calls, status %d, body %q", o.calls, resp.Status, body)
+	}
+	if v, _ := resp.Fields.Get("X-Version"); v != "2" {
+		t.Errorf("got X-Version %q, want %q", v, "2")
+	}
+	if v, _ := resp.Fields.Get("Cache-Control"); v != "max-age=10" {
+		t.Errorf("got Cache-Control %q, want %q", v, "max-age=10")
+	}
+
+	// The merged max-age directive should make the next request a hit, and
+	// the client's own conditional request should produce a 304.
+	resp, _ = get(t, c, "If-None-Match", `"v1"`)
+	if o.calls != 2 || resp.Status != 304 {
+		t.Errorf("got %d calls, status %d; want 2 calls, status 304", o.calls, resp.Status)
+	}
+}
+
+func TestVary(t *testing.T) {
+	withClock(time.Date(2015, 1, 1, 0, 0, 0, 0, time.UTC))
+	defer func() { now = time.Now }()
+
+	o := &origin{respond: func(req *heat.Request) *heat.Response {
+		lang, _ := req.Fields.Get("Accept-Language")
+		return response(200, lang, "Cache-Control", "max-age=60", "Vary", "Accept-Language")
+	}}
+
+	c := New(o, NewMemoryStorage())
+
+	get(t, c, "Accept-Language", "en")
+	get(t, c, "Accept-Language", "sv")
+
+	if _, body := get(t, c, "Accept-Language", "en"); o.calls != 2 || body != "en" {
+		t.Errorf("got %d calls, body %q; want 2 calls, body %q", o.calls, body, "en")
+	}
+	if _, body := get(t, c, "Accept-Language", "sv"); o.calls != 2 || body != "sv" {
+		t.Errorf("got %d calls, body %q; want 2 calls, body %q", o.calls, body, "sv")
+	}
+}
+
+func TestNoStore(t *testing.T) {
+	withClock(time.Date(2015, 1, 1, 0, 0, 0, 0, time.UTC))
+	defer func() { now = time.Now }()
+
+	o := &origin{respond: func(req *heat.Request) *heat.Response {
+		return response(200, "x", "Cache-Control", "max-age=60, no-store")
+	}}
+
+	c := New(o, NewMemoryStorage())
+
+	get(t, c)
+	get(t, c)
+
+	if o.calls != 2 {
+		t.Errorf("got %d calls, want 2", o.calls)
+	}
+}
+
+func TestHeuristicFreshness(t *testing.T) {
+	t0 := time.Date(2015, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	e := &Entry{
+		Status: 200,
+		Fields: heat.Fields{
+			{Name: "Date", Value: heat.FormatHTTPDate(t0)},
+			{Name: "Last-Modified", Value: heat.FormatHTTPDate(t0.Add(-10 * time.Hour))},
+		},
+		RequestTime:  t0,
+		ResponseTime: t0,
+	}
+
+	if d := freshnessLifetime(e, heat.ParseCacheControl(e.Fields), false); d != time.Hour {
+		t.Errorf("got lifetime %v, want %v", d, time.Hour)
+	}
+}
+
+func TestStaleWhileRevalidate(t *testing.T) {
+	advance := withClock(time.Date(2015, 1, 1, 0, 0, 0, 0, time.UTC))
+	defer func() { now = time.Now }()
+
+	var calls int
+	var host string
+	var gate = make(chan struct{})
+
+	o := TransportFunc(func(req *heat.Request) (*heat.Response, error) {
+		if calls++; calls == 1 {
+			return response(200, "v1", "Cache-Control", "max-age=10, stale-while-revalidate=60"), nil
+		}
+
+		// Hold up the background revalidation.
+		<-gate
+		host, _ = req.Fields.Get("Host")
+		return response(200, "v2", "Cache-Control", "max-age=10"), nil
+	})
+
+	c := New(o, NewMemoryStorage())
+
+	get(t, c)
+	advance(20 * time.Second)
+
+	// Both requests are served the stale response, and trigger a single
+	// revalidation between them.
+	for i := 0; i < 2; i++ {
+		u, _ := url.Parse("http://example.com/x")
+		req := heat.NewRequest("GET", u)
+
+		resp, err := c.RoundTrip(req)
+		if err != nil {
+			t.Fatalf("RoundTrip: %v", err)
+		}
+		if body, _ := io.ReadAll(resp.Body); string(body) != "v1" {
+			t.Errorf("got body %q, want %q", body, "v1")
+		}
+
+		// The caller may reuse its request right away.
+		req.Fields[0].Value = "reused"
+	}
+
+	close(gate)
+	c.wg.Wait()
+
+	if calls != 2 || host != "example.com" {
+		t.Errorf("got %d calls with Host %q, want 2 calls with Host %q", calls, host, "example.com")
+	}
+
+	if _, body := get(t, c); calls != 2 || body != "v2" {
+		t.Errorf("got %d calls, body %q; want 2 calls, body %q", calls, body, "v2")
+	}
+}
+
+func TestStaleIfError(t *testing.T) {
+	advance := withClock(time.Date(2015, 1, 1, 0, 0, 0, 0, time.UTC))
+	defer func() { now = time.Now }()
+
+	var fail error
+	var status = 200
+
+	o := TransportFunc(func(req *heat.Request) (*heat.Response, error) {
+		if fail != nil {
+			return nil, fail
+		}
+		return response(status, "v1", "Cache-Control", "max-age=10, stale-if-error=60"), nil
+	})
+
+	c := New(o, NewMemoryStorage())
+
+	get(t, c)
+	advance(20 * time.Second)
+
+	// Server errors and transport errors are both replaced by the stale
+	// response.
+	status = 503
+	if resp, body := get(t, c); resp.Status != 200 || body != "v1" {
+		t.Errorf("after 503: got status %d, body %q; want 200, %q", resp.Status, body, "v1")
+	}
+
+	fail = io.ErrUnexpectedEOF
+	if resp, body := get(t, c); resp.Status != 200 || body != "v1" {
+		t.Errorf("after error: got status %d, body %q; want 200, %q", resp.Status, body, "v1")
+	}
+
+	// But only within the stale-if-error window.
+	advance(time.Minute)
+
+	u, _ := url.Parse("http://example.com/x")
+	if _, err := c.RoundTrip(heat.NewRequest("GET", u)); err != io.ErrUnexpectedEOF {
+		t.Errorf("after window: got %v, want %v", err, io.ErrUnexpectedEOF)
+	}
+}
+
+func TestDiskStorage(t *testing.T) {
+	ds, err := NewDiskStorage(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewDiskStorage: %v", err)
+	}
+
+	t0 := time.Date(2015, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	entries := []*Entry{{
+		Status:       200,
+		Reason:       "OK",
+		Major:        1,
+		Minor:        1,
+		Fields:       heat.Fields{{Name: "ETag", Value: `"a"`}},
+		Body:         []byte("hello"),
+		Vary:         heat.Fields{{Name: "Accept-Language", Value: "en"}},
+		RequestTime:  t0,
+		ResponseTime: t0.Add(time.Second),
+	}}
+
+	if err := ds.Store("GET http://example.com/", entries); err != nil {
+		t.Fatalf("Store: %v", err)
+	}
+
+	out, err := ds.Load("GET http://example.com/")
+	if err != nil || !reflect.DeepEqual(out, entries) {
+		t.Errorf("Load:")
+		t.Errorf("  got  %+v, %v", out, err)
+		t.Errorf("  want %+v, %v", entries, nil)
+	}
+
+	if err := ds.Delete("GET http://example.com/"); err != nil {
+		t.Errorf("Delete: %v", err)
+	}
+
+	// Missing keys aren't errors.
+	if out, err := ds.Load("GET http://example.com/"); out != nil || err != nil {
+		t.Errorf("Load after Delete: got %v, %v, want nil, nil", out, err)
+	}
+	if err := ds.Delete("GET http://example.com/"); err != nil {
+		t.Errorf("Delete after Delete: %v", err)
+	}
+}
@@ -0,0 +1,124 @@
+package cache
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/erkl/heat"
+)
+
+// Upper bound for heuristic freshness lifetimes.
+const maxHeuristicLifetime = 24 * time.Hour
+
+// Status codes which are cacheable by default, as listed in RFC 9110
+// section 15.1.
+var heuristicallyCacheable = map[int]bool{
+	200: true,
+	203: true,
+	204: true,
+	300: true,
+	301: true,
+	308: true,
+	404: true,
+	405: true,
+	410: true,
+	414: true,
+	501: true,
+}
+
+// Status codes the cache understands well enough to store given explicit
+// freshness information.
+var understood = map[int]bool{
+	302: true,
+	307: true,
+}
+
+// freshnessLifetime calculates a stored response's freshness lifetime, as
+// described in RFC 9111 section 4.2.1.
+func freshnessLifetime(e *Entry, cc *heat.CacheControl, shared bool) time.Duration {
+	if shared && cc.SMaxAge >= 0 {
+		return seconds(cc.SMaxAge)
+	}
+
+	if cc.MaxAge >= 0 {
+		return seconds(cc.MaxAge)
+	}
+
+	if v, ok := e.Fields.Get("Expires"); ok {
+		// Invalid dates, such as "0", mean the response has already
+		// expired.
+		t, err := heat.ParseHTTPDate(v)
+		if err != nil {
+			return 0
+		}
+
+		if d := t.Sub(dateValue(e)); d > 0 {
+			return d
+		}
+
+		return 0
+	}
+
+	// Fall back to a heuristic lifetime of 10% of the time since the
+	// resource was last modified, as suggested by RFC 9111 section 4.2.2.
+	if heuristicallyCacheable[e.Status] || cc.Public {
+		if v, ok := e.Fields.Get("Last-Modified"); ok {
+			if t, err := heat.ParseHTTPDate(v); err == nil {
+				if d := dateValue(e).Sub(t) / 10; d > maxHeuristicLifetime {
+					return maxHeuristicLifetime
+				} else if d > 0 {
+					return d
+				}
+			}
+		}
+	}
+
+	return 0
+}
+
+// currentAge calculates a stored response's current age, as described in
+// RFC 9111 section 4.2.3.
+func currentAge(e *Entry, now time.Time) time.Duration {
+	var ageValue time.Duration
+
+	if v, ok := e.Fields.Get("Age"); ok {
+		if n, err := strconv.ParseInt(v, 10, 64); err == nil && n >= 0 {
+			ageValue = seconds(n)
+		}
+	}
+
+	apparentAge := e.ResponseTime.Sub(dateValue(e))
+	if apparentAge < 0 {
+		apparentAge = 0
+	}
+
+	responseDelay := e.ResponseTime.Sub(e.RequestTime)
+	correctedAgeValue := ageValue + responseDelay
+
+	correctedInitialAge := apparentAge
+	if correctedAgeValue > correctedInitialAge {
+		correctedInitialAge = correctedAgeValue
+	}
+
+	residentTime := now.Sub(e.ResponseTime)
+
+	return correctedInitialAge + residentTime
+}
+
+// dateValue returns the value of the response's "Date" field, falling back
+// to the time it was received.
+func dateValue(e *Entry) time.Time {
+	if v, ok := e.Fields.Get("Date"); ok {
+		if t, err := heat.ParseHTTPDate(v); err == nil {
+			return t
+		}
+	}
+	return e.ResponseTime
+}
+
+func seconds(n int64) time.Duration {
+	if n > int64(1<<63-1)/int64(time.Second) {
+		return 1<<63 - 1
+	}
+	return time.Duration(n) * time.Second
+}
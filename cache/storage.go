@@ -0,0 +1,156 @@
+package cache
+
+import (
+	"crypto/sha256"
+	"encoding/gob"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/erkl/heat"
+)
+
+// The Entry struct represents a stored response.
+type Entry struct {
+	// Status-Line, header fields and the full message body.
+	Status int
+	Reason string
+	Major  int
+	Minor  int
+	Fields heat.Fields
+	Body   []byte
+
+	// Values of the request fields nominated by the response's "Vary"
+	// field, used to select between multiple stored variants.
+	Vary heat.Fields
+
+	// Time at which the request was sent and the response was received,
+	// needed to calculate the response's age.
+	RequestTime  time.Time
+	ResponseTime time.Time
+}
+
+// The Storage interface is implemented by cache storage backends. A key
+// identifies a single resource, for which any number of variants may be
+// stored. Implementations must be safe for concurrent use, and should treat
+// stored entries as immutable.
+type Storage interface {
+	// Load returns all stored variants for a key, or nil if there are none.
+	Load(key string) ([]*Entry, error)
+
+	// Store replaces all variants stored for a key.
+	Store(key string, entries []*Entry) error
+
+	// Delete drops all variants stored for a key.
+	Delete(key string) error
+}
+
+// The MemoryStorage type is a Storage backend which keeps all entries in
+// memory.
+type MemoryStorage struct {
+	mu sync.Mutex
+	m  map[string][]*Entry
+}
+
+// NewMemoryStorage returns an empty MemoryStorage.
+func NewMemoryStorage() *MemoryStorage {
+	return &MemoryStorage{m: make(map[string][]*Entry)}
+}
+
+// Load implements the Storage interface.
+func (ms *MemoryStorage) Load(key string) ([]*Entry, error) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	return append([]*Entry(nil), ms.m[key]...), nil
+}
+
+// Store implements the Storage interface.
+func (ms *MemoryStorage) Store(key string, entries []*Entry) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	ms.m[key] = append([]*Entry(nil), entries...)
+	return nil
+}
+
+// Delete implements the Storage interface.
+func (ms *MemoryStorage) Delete(key string) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	delete(ms.m, key)
+	return nil
+}
+
+// The DiskStorage type is a Storage backend which keeps each key's entries
+// in a gob-encoded file, named after the SHA-256 hash of the key.
+type DiskStorage struct {
+	dir string
+}
+
+// NewDiskStorage returns a DiskStorage writing files to dir, which will be
+// created if necessary.
+func NewDiskStorage(dir string) (*DiskStorage, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	return &DiskStorage{dir}, nil
+}
+
+// Load implements the Storage interface.
+func (ds *DiskStorage) Load(key string) ([]*Entry, error) {
+	f, err := os.Open(ds.path(key))
+	if err != nil {
+		if os.IsNotExist(err) {
+			err = nil
+		}
+		return nil, err
+	}
+
+	defer f.Close()
+
+	var entries []*Entry
+	if err := gob.NewDecoder(f).Decode(&entries); err != nil {
+		return nil, err
+	}
+
+	return entries, nil
+}
+
+// Store implements the Storage interface. Files are replaced atomically, so
+// concurrent readers will never observe a partially written file.
+func (ds *DiskStorage) Store(key string, entries []*Entry) error {
+	f, err := os.CreateTemp(ds.dir, ".tmp-")
+	if err != nil {
+		return err
+	}
+
+	if err := gob.NewEncoder(f).Encode(entries); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return err
+	}
+
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+
+	return os.Rename(f.Name(), ds.path(key))
+}
+
+// Delete implements the Storage interface.
+func (ds *DiskStorage) Delete(key string) error {
+	if err := os.Remove(ds.path(key)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+func (ds *DiskStorage) path(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return filepath.Join(ds.dir, hex.EncodeToString(sum[:]))
+}
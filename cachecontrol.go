@@ -0,0 +1,237 @@
+package heat
+
+import (
+	"strconv"
+	"strings"
+)
+
+// The CacheControl struct represents the directives of one or more
+// "Cache-Control" fields, as described in RFC 9111 section 5.2. Both request
+// and response directives are covered.
+//
+// Directives with a delta-seconds argument use -1 to indicate their absence,
+// which is why new values should be created using NewCacheControl.
+type CacheControl struct {
+	MaxAge               int64
+	SMaxAge              int64
+	MaxStale             int64 // MaxStaleAny if present without a value.
+	MinFresh             int64
+	StaleWhileRevalidate int64
+	StaleIfError         int64
+
+	// The no-cache and private directives optionally list the names of
+	// fields they apply to.
+	NoCache       bool
+	NoCacheFields []string
+	Private       bool
+	PrivateFields []string
+
+	NoStore         bool
+	NoTransform     bool
+	Public          bool
+	MustRevalidate  bool
+	ProxyRevalidate bool
+	MustUnderstand  bool
+	OnlyIfCached    bool
+	Immutable       bool
+
+	// Unrecognized directives, in order of appearance. Values are stored
+	// without quotes.
+	Extensions []Field
+}
+
+// MaxStaleAny is the MaxStale value of a "max-stale" directive without an
+// argument, meaning the client will accept a stale response of any age.
+const MaxStaleAny = 1<<63 - 1
+
+// NewCacheControl returns a CacheControl value without any directives.
+func NewCacheControl() *CacheControl {
+	return &CacheControl{
+		MaxAge:               -1,
+		SMaxAge:              -1,
+		MaxStale:             -1,
+		MinFresh:             -1,
+		StaleWhileRevalidate: -1,
+		StaleIfError:         -1,
+	}
+}
+
+// ParseCacheControl parses all "Cache-Control" fields in a header. If the
+// same directive appears more than once, the most restrictive (i.e. lowest)
+// value is used, and invalid delta-seconds values are treated as 0.
+func ParseCacheControl(fields Fields) *CacheControl {
+	cc := NewCacheControl()
+
+	for _, f := range fields {
+		if !f.Is("Cache-Control") {
+			continue
+		}
+
+		splitList(f.Value, func(s string) bool {
+			name, value, hasValue := s, "", false
+
+			if i := strings.IndexByte(s, '='); i >= 0 {
+				name, value, hasValue = strtrim(s[:i]), unquote(strtrim(s[i+1:])), true
+			}
+
+			switch strings.ToLower(name) {
+			case "max-age":
+				cc.MaxAge = minDelta(cc.MaxAge, value)
+			case "s-maxage":
+				cc.SMaxAge = minDelta(cc.SMaxAge, value)
+			case "max-stale":
+				if hasValue {
+					cc.MaxStale = minDelta(cc.MaxStale, value)
+				} else if cc.MaxStale < 0 {
+					cc.MaxStale = MaxStaleAny
+				}
+			case "min-fresh":
+				cc.MinFresh = minDelta(cc.MinFresh, value)
+			case "stale-while-revalidate":
+				cc.StaleWhileRevalidate = minDelta(cc.StaleWhileRevalidate, value)
+			case "stale-if-error":
+				cc.StaleIfError = minDelta(cc.StaleIfError, value)
+			case "no-cache":
+				cc.NoCache = true
+				cc.NoCacheFields = appendFieldNames(cc.NoCacheFields, value)
+			case "private":
+				cc.Private = true
+				cc.PrivateFields = appendFieldNames(cc.PrivateFields, value)
+			case "no-store":
+				cc.NoStore = true
+			case "no-transform":
+				cc.NoTransform = true
+			case "public":
+				cc.Public = true
+			case "must-revalidate":
+				cc.MustRevalidate = true
+			case "proxy-revalidate":
+				cc.ProxyRevalidate = true
+			case "must-understand":
+				cc.MustUnderstand = true
+			case "only-if-cached":
+				cc.OnlyIfCached = true
+			case "immutable":
+				cc.Immutable = true
+			default:
+				cc.Extensions = append(cc.Extensions, Field{name, value})
+			}
+
+			return true
+		})
+	}
+
+	return cc
+}
+
+// String serializes the directives for use as a "Cache-Control" field value.
+func (cc *CacheControl) String() string {
+	var b []byte
+
+	add := func(name string) {
+		if len(b) > 0 {
+			b = append(b, ", "...)
+		}
+		b = append(b, name...)
+	}
+
+	delta := func(name string, x int64) {
+		if x >= 0 {
+			add(name)
+			b = append(b, '=')
+			b = strconv.AppendInt(b, x, 10)
+		}
+	}
+
+	flag := func(name string, set bool, fields []string) {
+		if set {
+			add(name)
+			if len(fields) > 0 {
+				b = append(b, '=')
+				b = append(b, quote(strings.Join(fields, ", "))...)
+			}
+		}
+	}
+
+	flag("no-store", cc.NoStore, nil)
+	flag("no-cache", cc.NoCache, cc.NoCacheFields)
+	flag("private", cc.Private, cc.PrivateFields)
+	flag("public", cc.Public, nil)
+	delta("max-age", cc.MaxAge)
+	delta("s-maxage", cc.SMaxAge)
+
+	if cc.MaxStale == MaxStaleAny {
+		add("max-stale")
+	} else {
+		delta("max-stale", cc.MaxStale)
+	}
+
+	delta("min-fresh", cc.MinFresh)
+	flag("must-revalidate", cc.MustRevalidate, nil)
+	flag("proxy-revalidate", cc.ProxyRevalidate, nil)
+	flag("must-understand", cc.MustUnderstand, nil)
+	flag("no-transform", cc.NoTransform, nil)
+	flag("only-if-cached", cc.OnlyIfCached, nil)
+	flag("immutable", cc.Immutable, nil)
+	delta("stale-while-revalidate", cc.StaleWhileRevalidate)
+	delta("stale-if-error", cc.StaleIfError)
+
+	for _, ext := range cc.Extensions {
+		add(ext.Name)
+		if ext.Value != "" {
+			b = append(b, '=')
+			b = append(b, quoteIfNeeded(ext.Value)...)
+		}
+	}
+
+	return string(b)
+}
+
+// CacheControl parses the list's "Cache-Control" fields.
+func (fs *Fields) CacheControl() *CacheControl {
+	return ParseCacheControl(*fs)
+}
+
+// SetCacheControl replaces any "Cache-Control" fields in the list with the
+// serialized form of cc. If cc contains no directives, the fields are simply
+// removed.
+func (fs *Fields) SetCacheControl(cc *CacheControl) {
+	if s := cc.String(); s != "" {
+		fs.Set("Cache-Control", s)
+	} else {
+		fs.Remove("Cache-Control")
+	}
+}
+
+// maxDeltaSeconds is the largest delta-seconds value, as recommended by
+// RFC 9111 section 1.2.2. Larger values are treated as this one.
+const maxDeltaSeconds = 1 << 31
+
+func minDelta(prev int64, value string) int64 {
+	x, ok := atoi([]byte(value))
+
+	switch {
+	case len(strings.TrimLeft(value, "0")) > 10 && strings.Trim(value, "0123456789") == "":
+		// Values this long can't be parsed reliably, but are bound to be
+		// too large anyway.
+		x = maxDeltaSeconds
+	case !ok || x < 0:
+		x = 0
+	case x > maxDeltaSeconds:
+		x = maxDeltaSeconds
+	}
+
+	if prev >= 0 && prev < x {
+		return prev
+	}
+
+	return x
+}
+
+func appendFieldNames(names []string, value string) []string {
+	splitList(value, func(s string) bool {
+		names = append(names, s)
+		return true
+	})
+	return names
+}
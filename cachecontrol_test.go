@@ -0,0 +1,87 @@
+package heat
+
+import (
+	"reflect"
+	"testing"
+)
+
+var cacheControlTests = []struct {
+	in  Fields
+	out func(cc *CacheControl)
+	str string
+}{
+	{
+		Fields{},
+		func(cc *CacheControl) {},
+		"",
+	},
+	{
+		Fields{{"Cache-Control", "public, max-age=3600, immutable"}},
+		func(cc *CacheControl) {
+			cc.Public = true
+			cc.MaxAge = 3600
+			cc.Immutable = true
+		},
+		"public, max-age=3600, immutable",
+	},
+	{
+		Fields{{"Cache-Control", `no-cache="Set-Cookie, X-Foo", private`}, {"cache-control", "max-age=10, MAX-AGE=\"5\", s-maxage=x"}},
+		func(cc *CacheControl) {
+			cc.NoCache = true
+			cc.NoCacheFields = []string{"Set-Cookie", "X-Foo"}
+			cc.Private = true
+			cc.MaxAge = 5
+			cc.SMaxAge = 0
+		},
+		`no-cache="Set-Cookie, X-Foo", private, max-age=5, s-maxage=0`,
+	},
+	{
+		Fields{{"Cache-Control", `max-stale, stale-while-revalidate=60, stale-if-error=600, community="UCI", ext`}},
+		func(cc *CacheControl) {
+			cc.MaxStale = MaxStaleAny
+			cc.StaleWhileRevalidate = 60
+			cc.StaleIfError = 600
+			cc.Extensions = []Field{{"community", "UCI"}, {"ext", ""}}
+		},
+		`max-stale, stale-while-revalidate=60, stale-if-error=600, community=UCI, ext`,
+	},
+	{
+		Fields{{"Cache-Control", "max-age=99999999999999999999, s-maxage=2147483649, min-fresh=2147483647"}},
+		func(cc *CacheControl) {
+			cc.MaxAge = 2147483648
+			cc.SMaxAge = 2147483648
+			cc.MinFresh = 2147483647
+		},
+		"max-age=2147483648, s-maxage=2147483648, min-fresh=2147483647",
+	},
+	{
+		Fields{{"Cache-Control", "max-age=18446744073709551615, s-maxage=18446744073709551616, stale-if-error=00000000000000000001"}},
+		func(cc *CacheControl) {
+			cc.MaxAge = 2147483648
+			cc.SMaxAge = 2147483648
+			cc.StaleIfError = 1
+		},
+		"max-age=2147483648, s-maxage=2147483648, stale-if-error=1",
+	},
+}
+
+func TestCacheControl(t *testing.T) {
+	for _, test := range cacheControlTests {
+		want := NewCacheControl()
+		test.out(want)
+
+		out := ParseCacheControl(test.in)
+		if !reflect.DeepEqual(out, want) {
+			t.Errorf("ParseCacheControl(%q):", test.in)
+			t.Errorf("  got  %+v", out)
+			t.Errorf("  want %+v", want)
+			continue
+		}
+
+		if str := out.String(); str != test.str {
+			t.Errorf("(%+v).String():", out)
+			t.Errorf("  got  %q", str)
+			t.Errorf("  want %q", test.str)
+		}
+	}
+}
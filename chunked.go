@@ -46,8 +46,15 @@ var dehex = [256]byte{
 	0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, // . . . . . . . .
 }
 
+// NewChunkedWriter returns a writer which encodes everything written to it
+// as chunks on w. Closing it writes the final, empty chunk, without closing w.
+func NewChunkedWriter(w xo.Writer) io.WriteCloser {
+	return &chunkedWriter{w, traceOf(w), [18]byte{16: '\r', 17: '\n'}}
+}
+
 type chunkedWriter struct {
 	w xo.Writer
+	t *Trace
 	b [18]byte
 }
 
@@ -76,6 +83,8 @@ func (cw *chunkedWriter) Write(chunk []byte) (int, error) {
 		return 0, err
 	}
 
+	cw.t.wroteChunk(len(chunk))
+
 	return len(chunk), nil
 }
 
@@ -91,6 +100,12 @@ func (cw *chunkedWriter) Close() error {
 type chunkedReader struct {
 	r xo.Reader
 	n int64
+
+	// Trailers are discarded unless this is non-nil.
+	trailers *Fields
+
+	// Optional trace, notified as each chunk starts.
+	trace *Trace
 }
 
 func (cr *chunkedReader) Read(buf []byte) (n int, err error) {
@@ -103,7 +118,7 @@ func (cr *chunkedReader) Read(buf []byte) (n int, err error) {
 
 		// End the stream after an empty chunk.
 		if cr.n == 0 {
-			if err = cr.discardTrailers(); err != nil {
+			if err = cr.readTrailers(); err != nil {
 				return 0, err
 			}
 			return 0, io.EOF
@@ -145,9 +160,66 @@ func (cr *chunkedReader) open() error {
 		return err
 	}
 
+	if cr.n, err = parseChunkSize(buf); err != nil {
+		return err
+	}
+
+	if cr.n > 0 {
+		cr.trace.gotChunk(cr.n)
+	}
+
+	return cr.r.Consume(len(buf))
+}
+
+func (cr *chunkedReader) close() error {
+	buf, err := xo.PeekTo(cr.r, '\n', 0)
+	if err != nil {
+		return err
+	}
+
+	if !isChunkLineEnd(buf) {
+		return ErrInvalidChunkedEncoding
+	}
+
+	return cr.r.Consume(len(buf))
+}
+
+func (cr *chunkedReader) readTrailers() error {
+	if cr.trailers != nil {
+		fields, err := readHeader(cr.r, *cr.trailers, nil)
+		if err != nil {
+			if err == errMalformedHeader {
+				err = ErrInvalidChunkedEncoding
+			}
+			return err
+		}
+
+		*cr.trailers = fields
+		return nil
+	}
+
+	for {
+		buf, err := xo.PeekTo(cr.r, '\n', 0)
+		if err != nil {
+			return err
+		}
+
+		// An empty line signals the end of trailers.
+		done := isChunkLineEnd(buf)
+
+		if err := cr.r.Consume(len(buf)); err != nil || done {
+			return err
+		}
+	}
+}
+
+// parseChunkSize parses a chunk size line, including its line break.
+func parseChunkSize(buf []byte) (int64, error) {
+	var n int64
+
 	// Quick check for invalid chunk size lines.
 	if len(buf) < 2 {
-		return ErrInvalidChunkedEncoding
+		return 0, ErrInvalidChunkedEncoding
 	}
 
 	// Trim the line ending.
@@ -162,17 +234,17 @@ func (cr *chunkedReader) open() error {
 	for i, c := range buf[:end] {
 		// Decode hex characters.
 		if x := dehex[c]; x <= 0xf {
-			if cr.n > 0x07ffffffffffffff {
-				return ErrInvalidChunkedEncoding
+			if n > 0x07ffffffffffffff {
+				return 0, ErrInvalidChunkedEncoding
 			}
 
-			cr.n = cr.n<<4 | int64(x)
+			n = n<<4 | int64(x)
 			continue
 		}
 
 		// The line must begin with at least one valid digit.
 		if i == 0 {
-			return ErrInvalidChunkedEncoding
+			return 0, ErrInvalidChunkedEncoding
 		}
 
 		// Chunk extensions are weird and seemingly unused, but RFC 2616
@@ -188,37 +260,14 @@ func (cr *chunkedReader) open() error {
 		}
 
 		// Any other case is an error.
-		return ErrInvalidChunkedEncoding
+		return 0, ErrInvalidChunkedEncoding
 	}
 
-	return cr.r.Consume(len(buf))
-}
-
-func (cr *chunkedReader) close() error {
-	buf, err := xo.PeekTo(cr.r, '\n', 0)
-	if err != nil {
-		return err
-	}
-
-	if len(buf) != 1 && buf[0] != '\r' {
-		return ErrInvalidChunkedEncoding
-	}
-
-	return cr.r.Consume(len(buf))
+	return n, nil
 }
 
-func (cr *chunkedReader) discardTrailers() error {
-	for {
-		buf, err := xo.PeekTo(cr.r, '\n', 0)
-		if err != nil {
-			return err
-		}
-
-		// An empty line signals the end of trailers.
-		done := (len(buf) == 1 || buf[0] == '\r')
-
-		if err := cr.r.Consume(len(buf)); err != nil || done {
-			return err
-		}
-	}
+// isChunkLineEnd reports whether a line is empty, as expected after each
+// chunk's data and at the end of trailers.
+func isChunkLineEnd(buf []byte) bool {
+	return len(buf) == 1 || buf[0] == '\r'
 }
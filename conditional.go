@@ -0,0 +1,111 @@
+package heat
+
+import (
+	"time"
+)
+
+// The Precondition type represents the outcome of evaluating a request's
+// conditional header fields.
+type Precondition int
+
+const (
+	PreconditionPassed      Precondition = iota // Respond normally.
+	PreconditionNotModified                     // Respond with "304 Not Modified".
+	PreconditionFailed                          // Respond with "412 Precondition Failed".
+)
+
+// CheckPreconditions evaluates the "If-Match", "If-Unmodified-Since",
+// "If-None-Match" and "If-Modified-Since" fields of a request against the
+// current validators of the target resource, following the precedence rules
+// of RFC 9110 section 13.2.2. Either validator may be left empty if the
+// resource doesn't have one. The "*" wildcard is considered to match when
+// either validator is present, i.e. when a current representation exists.
+//
+// The "If-Range" field is not considered; see IfRange.
+func CheckPreconditions(req *Request, etag string, lastModified time.Time) Precondition {
+	var exists = etag != "" || !lastModified.IsZero()
+	var safe = req.Method == "GET" || req.Method == "HEAD"
+
+	// HTTP-dates have a resolution of one second.
+	lastModified = lastModified.Truncate(time.Second)
+
+	// Step 1 and 2: If-Match, or failing that, If-Unmodified-Since.
+	if v, ok := joinList(req.Fields, "If-Match"); ok {
+		if !ifMatch(v, etag, exists) {
+			return PreconditionFailed
+		}
+	} else if v, ok := req.Fields.Get("If-Unmodified-Since"); ok && !lastModified.IsZero() {
+		if t, ok := parseHTTPDate(strtrim(v)); ok && lastModified.After(t) {
+			return PreconditionFailed
+		}
+	}
+
+	// Step 3 and 4: If-None-Match, or failing that, If-Modified-Since.
+	if v, ok := joinList(req.Fields, "If-None-Match"); ok {
+		if !ifNoneMatch(v, etag, exists) {
+			if safe {
+				return PreconditionNotModified
+			}
+			return PreconditionFailed
+		}
+	} else if v, ok := req.Fields.Get("If-Modified-Since"); ok && safe && !lastModified.IsZero() {
+		if t, ok := parseHTTPDate(strtrim(v)); ok && !lastModified.After(t) {
+			return PreconditionNotModified
+		}
+	}
+
+	return PreconditionPassed
+}
+
+// joinList combines the values of every field with a particular name into
+// a single comma-separated list, as allowed by RFC 9110 section 5.3.
+func joinList(fields Fields, name string) (string, bool) {
+	var v string
+	var found bool
+
+	for _, f := range fields {
+		if !f.Is(name) {
+			continue
+		}
+
+		if v == "" {
+			v = f.Value
+		} else if strtrim(f.Value) != "" {
+			v += ", " + f.Value
+		}
+
+		found = true
+	}
+
+	return v, found
+}
+
+func ifMatch(v, etag string, exists bool) bool {
+	if strtrim(v) == "*" {
+		return exists
+	}
+
+	var match bool
+
+	splitETags(v, func(s string) bool {
+		match = etagStrongMatch(s, etag)
+		return !match
+	})
+
+	return match
+}
+
+func ifNoneMatch(v, etag string, exists bool) bool {
+	if strtrim(v) == "*" {
+		return !exists
+	}
+
+	var match bool
+
+	splitETags(v, func(s string) bool {
+		match = etagWeakMatch(s, etag)
+		return !match
+	})
+
+	return !match
+}
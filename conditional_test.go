@@ -0,0 +1,63 @@
+package heat
+
+import (
+	"testing"
+	"time"
+)
+
+var lastModified = time.Date(2015, 10, 21, 7, 28, 0, 0, time.UTC)
+
+var checkPreconditionsTests = []struct {
+	method string
+	fields Fields
+	etag   string
+	out    Precondition
+}{
+	{"GET", Fields{}, `"a"`, PreconditionPassed},
+
+	// If-Match uses the strong comparison function.
+	{"PUT", Fields{{"If-Match", `"a"`}}, `"a"`, PreconditionPassed},
+	{"PUT", Fields{{"If-Match", `"x", "a"`}}, `"a"`, PreconditionPassed},
+	{"PUT", Fields{{"If-Match", `W/"a"`}}, `"a"`, PreconditionFailed},
+	{"PUT", Fields{{"If-Match", `"a"`}}, `W/"a"`, PreconditionFailed},
+	{"PUT", Fields{{"If-Match", `*`}}, `"a"`, PreconditionPassed},
+	{"PUT", Fields{{"If-Match", `*`}}, ``, PreconditionPassed},
+
+	// If-None-Match uses the weak comparison function.
+	{"GET", Fields{{"If-None-Match", `W/"a"`}}, `"a"`, PreconditionNotModified},
+	{"GET", Fields{{"If-None-Match", `"x,y", "b"`}}, `"a"`, PreconditionPassed},
+	{"GET", Fields{{"If-None-Match", `"x,y", "a"`}}, `"a"`, PreconditionNotModified},
+	{"POST", Fields{{"If-None-Match", `*`}}, `"a"`, PreconditionFailed},
+
+	// Both are list fields, which may be split over several lines.
+	{"PUT", Fields{{"If-Match", `"x"`}, {"If-Match", `"a"`}}, `"a"`, PreconditionPassed},
+	{"PUT", Fields{{"If-Match", `"x"`}, {"If-Match", `"y"`}}, `"a"`, PreconditionFailed},
+	{"GET", Fields{{"If-None-Match", `"x"`}, {"If-None-Match", `"a"`}}, `"a"`, PreconditionNotModified},
+	{"GET", Fields{{"If-None-Match", ``}, {"If-None-Match", `"a"`}}, `"a"`, PreconditionNotModified},
+
+	// Date-based conditions.
+	{"PUT", Fields{{"If-Unmodified-Since", "Wed, 21 Oct 2015 07:28:00 GMT"}}, ``, PreconditionPassed},
+	{"PUT", Fields{{"If-Unmodified-Since", "Wed, 21 Oct 2015 07:27:59 GMT"}}, ``, PreconditionFailed},
+	{"PUT", Fields{{"If-Unmodified-Since", "garbage"}}, ``, PreconditionPassed},
+	{"GET", Fields{{"If-Modified-Since", "Wed, 21 Oct 2015 07:28:00 GMT"}}, ``, PreconditionNotModified},
+	{"GET", Fields{{"If-Modified-Since", "Wed, 21 Oct 2015 07:27:59 GMT"}}, ``, PreconditionPassed},
+	{"POST", Fields{{"If-Modified-Since", "Wed, 21 Oct 2015 07:28:00 GMT"}}, ``, PreconditionPassed},
+
+	// Precedence.
+	{"GET", Fields{{"If-Match", `"a"`}, {"If-Unmodified-Since", "Wed, 21 Oct 2015 07:27:59 GMT"}}, `"a"`, PreconditionPassed},
+	{"GET", Fields{{"If-None-Match", `"b"`}, {"If-Modified-Since", "Wed, 21 Oct 2015 07:28:00 GMT"}}, `"a"`, PreconditionPassed},
+	{"GET", Fields{{"If-Match", `"b"`}, {"If-None-Match", `"a"`}}, `"a"`, PreconditionFailed},
+}
+
+func TestCheckPreconditions(t *testing.T) {
+	for _, test := range checkPreconditionsTests {
+		req := &Request{Method: test.method, Fields: test.fields}
+
+		out := CheckPreconditions(req, test.etag, lastModified)
+		if out != test.out {
+			t.Errorf("CheckPreconditions(%s %q, %q):", test.method, test.fields, test.etag)
+			t.Errorf("  got  %v", out)
+			t.Errorf("  want %v", test.out)
+		}
+	}
+}
@@ -0,0 +1,135 @@
+package heat
+
+import (
+	"context"
+	"io"
+	"time"
+
+	"github.com/erkl/xo"
+)
+
+// The Deadliner interface is implemented by connections supporting read and
+// write deadlines, such as net.Conn.
+type Deadliner interface {
+	SetReadDeadline(t time.Time) error
+	SetWriteDeadline(t time.Time) error
+}
+
+// A deadline in the past, for interrupting blocked reads and writes.
+var aLongTimeAgo = time.Unix(1, 0)
+
+// ReadRequestHeaderContext works like ReadRequestHeader, but gives up once
+// ctx is done, by setting conn's read deadline to a time in the past. When
+// that happens ctx.Err() is returned, and the deadline is left in place: as
+// the header may have been read in part, the connection is no longer usable.
+//
+// The same goes for the other context-aware functions.
+func ReadRequestHeaderContext(ctx context.Context, conn Deadliner, r xo.Reader) (*Request, error) {
+	var req *Request
+
+	err := withContext(ctx, conn.SetReadDeadline, func() (err error) {
+		req, err = ReadRequestHeader(r)
+		return err
+	})
+
+	return req, err
+}
+
+// ReadResponseHeaderContext works like ReadResponseHeader, but gives up once
+// ctx is done.
+func ReadResponseHeaderContext(ctx context.Context, conn Deadliner, r xo.Reader) (*Response, error) {
+	var resp *Response
+
+	err := withContext(ctx, conn.SetReadDeadline, func() (err error) {
+		resp, err = ReadResponseHeader(r)
+		return err
+	})
+
+	return resp, err
+}
+
+// WriteRequestHeaderContext works like WriteRequestHeader, but gives up once
+// ctx is done, by setting conn's write deadline to a time in the past.
+func WriteRequestHeaderContext(ctx context.Context, conn Deadliner, w xo.Writer, req *Request) error {
+	return withContext(ctx, conn.SetWriteDeadline, func() error {
+		return WriteRequestHeader(w, req)
+	})
+}
+
+// WriteResponseHeaderContext works like WriteResponseHeader, but gives up
+// once ctx is done.
+func WriteResponseHeaderContext(ctx context.Context, conn Deadliner, w xo.Writer, resp *Response) error {
+	return withContext(ctx, conn.SetWriteDeadline, func() error {
+		return WriteResponseHeader(w, resp)
+	})
+}
+
+// WriteBodyContext works like WriteBody, but gives up once ctx is done.
+// Reads from src aren't interrupted.
+func WriteBodyContext(ctx context.Context, conn Deadliner, dst xo.Writer, src io.Reader, size BodySize) error {
+	return withContext(ctx, conn.SetWriteDeadline, func() error {
+		return WriteBody(dst, src, size)
+	})
+}
+
+// OpenBodyContext works like OpenBody, but each read from the returned body
+// gives up once ctx is done. As the body may have been read in part, this
+// always leaves the connection unusable, even when ctx is done before a read
+// starts.
+func OpenBodyContext(ctx context.Context, conn Deadliner, src xo.Reader, size BodySize) (io.Reader, error) {
+	body, err := OpenBody(src, size)
+	if body == nil || err != nil {
+		return body, err
+	}
+
+	return &contextBody{body, ctx, conn}, nil
+}
+
+type contextBody struct {
+	r    io.Reader
+	ctx  context.Context
+	conn Deadliner
+}
+
+func (cb *contextBody) Read(buf []byte) (n int, err error) {
+	if err := cb.ctx.Err(); err != nil {
+		cb.conn.SetReadDeadline(aLongTimeAgo)
+		return 0, err
+	}
+
+	err = withContext(cb.ctx, cb.conn.SetReadDeadline, func() error {
+		n, err = cb.r.Read(buf)
+		return err
+	})
+
+	return n, err
+}
+
+// withContext calls fn, interrupting it with a deadline in the past if ctx
+// is done before it returns.
+func withContext(ctx context.Context, setDeadline func(t time.Time) error, fn func() error) error {
+	if ctx.Done() == nil {
+		return fn()
+	}
+
+	// As fn hasn't been called yet, the connection is no less usable than
+	// before. Callers resuming an earlier read or write must check for this
+	// case themselves.
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	stop := context.AfterFunc(ctx, func() {
+		setDeadline(aLongTimeAgo)
+	})
+
+	err := fn()
+
+	// Once the deadline has been set, report the cancellation even if fn
+	// happened to finish first.
+	if !stop() {
+		return ctx.Err()
+	}
+
+	return err
+}
@@ -0,0 +1,110 @@
+package heat
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestReadRequestHeaderContext(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+	defer server.Close()
+
+	// Send part of a header, then stall.
+	go io.WriteString(client, "GET / HTTP/1.1\r\nHost: a\r\n")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	r := NewBufioReader(bufio.NewReader(server))
+
+	req, err := ReadRequestHeaderContext(ctx, server, r)
+	if req != nil || err != context.DeadlineExceeded {
+		t.Errorf("ReadRequestHeaderContext:")
+		t.Errorf("  got  %v, %v", req, err)
+		t.Errorf("  want %v, %v", nil, context.DeadlineExceeded)
+	}
+
+	// The connection must stay unusable.
+	if _, err := server.Read(make([]byte, 1)); err == nil {
+		t.Errorf("Read after cancellation: got nil error")
+	}
+}
+
+func TestOpenBodyContext(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+	defer server.Close()
+
+	go io.WriteString(client, "hello")
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	r := NewBufioReader(bufio.NewReader(server))
+
+	body, err := OpenBodyContext(ctx, server, r, 10)
+	if err != nil {
+		t.Fatalf("OpenBodyContext: %v", err)
+	}
+
+	if _, err := io.ReadFull(body, make([]byte, 5)); err != nil {
+		t.Fatalf("Read: %v", err)
+	}
+
+	// Reads after cancellation fail right away, even though none was in
+	// progress.
+	cancel()
+
+	if n, err := body.Read(make([]byte, 5)); n != 0 || err != context.Canceled {
+		t.Errorf("Read after cancellation:")
+		t.Errorf("  got  %d, %v", n, err)
+		t.Errorf("  want %d, %v", 0, context.Canceled)
+	}
+
+	// The body was only read in part, so the connection must be unusable.
+	go io.WriteString(client, "world")
+
+	if _, err := server.Read(make([]byte, 1)); err == nil {
+		t.Errorf("Read from the connection: got nil error")
+	}
+}
+
+func TestWriteBodyContext(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	// Nobody reads from the other end, so writing blocks until canceled.
+	time.AfterFunc(20*time.Millisecond, cancel)
+
+	w := NewBufioWriter(bufio.NewWriterSize(server, 16))
+	body := &fixedReader{zeroReader{}, 1 << 10}
+
+	if err := WriteBodyContext(ctx, server, w, body, 1<<10); err != context.Canceled {
+		t.Errorf("WriteBodyContext:")
+		t.Errorf("  got  %v", err)
+		t.Errorf("  want %v", context.Canceled)
+	}
+
+	// An already canceled context fails right away.
+	if err := WriteBodyContext(ctx, server, w, body, 1<<10); err != context.Canceled {
+		t.Errorf("WriteBodyContext (canceled):")
+		t.Errorf("  got  %v", err)
+		t.Errorf("  want %v", context.Canceled)
+	}
+}
+
+type zeroReader struct{}
+
+func (zeroReader) Read(buf []byte) (int, error) {
+	for i := range buf {
+		buf[i] = 0
+	}
+	return len(buf), nil
+}
@@ -0,0 +1,424 @@
+package heat
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The Cookie struct represents a single name/value pair from a "Cookie"
+// request header field.
+type Cookie struct {
+	Name, Value string
+}
+
+// The SameSite type represents the value of a Set-Cookie "SameSite"
+// attribute.
+type SameSite int
+
+const (
+	SameSiteDefault SameSite = iota // Attribute not present.
+	SameSiteLax
+	SameSiteStrict
+	SameSiteNone
+)
+
+// The SetCookie struct represents a single "Set-Cookie" response header
+// field, as described in RFC 6265.
+type SetCookie struct {
+	Name  string
+	Value string
+
+	// Expiry attributes. A zero Expires value means the attribute was not
+	// present. A zero MaxAge means no "Max-Age" attribute, and a negative
+	// MaxAge is equivalent to "Max-Age=0", i.e. delete the cookie now.
+	Expires time.Time
+	MaxAge  int
+
+	Domain string
+	Path   string
+
+	Secure      bool
+	HttpOnly    bool
+	Partitioned bool
+	SameSite    SameSite
+
+	// Unrecognized attributes, stored verbatim (e.g. "Priority=High").
+	Extensions []string
+}
+
+// ParseCookies returns all name/value pairs found in the "Cookie" fields of
+// a header. Malformed pairs are silently skipped.
+func ParseCookies(fields Fields) []Cookie {
+	var cookies []Cookie
+
+	for _, f := range fields {
+		if !f.Is("Cookie") {
+			continue
+		}
+
+		for v := f.Value; v != ""; {
+			var pair string
+
+			if i := strings.IndexByte(v, ';'); i >= 0 {
+				pair, v = v[:i], v[i+1:]
+			} else {
+				pair, v = v, ""
+			}
+
+			name, value, ok := parseCookiePair(strtrim(pair))
+			if !ok {
+				continue
+			}
+
+			cookies = append(cookies, Cookie{name, value})
+		}
+	}
+
+	return cookies
+}
+
+// ParseSetCookie parses the value of a single "Set-Cookie" field.
+func ParseSetCookie(s string) (*SetCookie, error) {
+	var pair string
+
+	if i := strings.IndexByte(s, ';'); i >= 0 {
+		pair, s = s[:i], s[i+1:]
+	} else {
+		pair, s = s, ""
+	}
+
+	name, value, ok := parseCookiePair(strtrim(pair))
+	if !ok {
+		return nil, ErrInvalidCookie
+	}
+
+	c := &SetCookie{
+		Name:  name,
+		Value: value,
+	}
+
+	for s != "" {
+		var attr string
+
+		if i := strings.IndexByte(s, ';'); i >= 0 {
+			attr, s = strtrim(s[:i]), s[i+1:]
+		} else {
+			attr, s = strtrim(s), ""
+		}
+
+		if attr == "" || attr == ";" {
+			continue
+		}
+
+		key, val := attr, ""
+		if i := strings.IndexByte(attr, '='); i >= 0 {
+			key, val = strtrim(attr[:i]), strtrim(attr[i+1:])
+		}
+
+		switch {
+		case strcaseeq(key, "Expires"):
+			if t, ok := parseCookieDate(val); ok {
+				c.Expires = t
+			}
+
+		case strcaseeq(key, "Max-Age"):
+			// RFC 6265 section 5.2.2: ignore the attribute unless the
+			// value is a (possibly negative) decimal integer.
+			n, err := strconv.Atoi(val)
+			if err != nil || (val[0] != '-' && (val[0] < '0' || val[0] > '9')) {
+				continue
+			}
+			if n <= 0 {
+				c.MaxAge = -1
+			} else {
+				c.MaxAge = n
+			}
+
+		case strcaseeq(key, "Domain"):
+			// Ignore a leading dot, as per RFC 6265 section 5.2.3.
+			c.Domain = strings.TrimPrefix(val, ".")
+
+		case strcaseeq(key, "Path"):
+			if val != "" && val[0] == '/' {
+				c.Path = val
+			}
+
+		case strcaseeq(key, "Secure"):
+			c.Secure = true
+
+		case strcaseeq(key, "HttpOnly"):
+			c.HttpOnly = true
+
+		case strcaseeq(key, "Partitioned"):
+			c.Partitioned = true
+
+		case strcaseeq(key, "SameSite"):
+			switch {
+			case strcaseeq(val, "Lax"):
+				c.SameSite = SameSiteLax
+			case strcaseeq(val, "Strict"):
+				c.SameSite = SameSiteStrict
+			case strcaseeq(val, "None"):
+				c.SameSite = SameSiteNone
+			}
+
+		default:
+			c.Extensions = append(c.Extensions, attr)
+		}
+	}
+
+	return c, nil
+}
+
+// String serializes the cookie for use as the value of a "Set-Cookie" field.
+// Like net/http, it refuses to emit anything that could alter the field's
+// meaning: an invalid name yields an empty string, invalid bytes are dropped
+// from the value and path, and an invalid domain or extension is omitted.
+func (c *SetCookie) String() string {
+	if c.Name == "" || !isToken(c.Name) {
+		return ""
+	}
+
+	var b []byte
+
+	b = append(b, c.Name...)
+	b = append(b, '=')
+	b = appendCookieValue(b, c.Value)
+
+	if !c.Expires.IsZero() {
+		b = append(b, "; Expires="...)
+		b = AppendHTTPDate(b, c.Expires)
+	}
+
+	if c.MaxAge > 0 {
+		b = append(b, "; Max-Age="...)
+		b = strconv.AppendInt(b, int64(c.MaxAge), 10)
+	} else if c.MaxAge < 0 {
+		b = append(b, "; Max-Age=0"...)
+	}
+
+	if c.Domain != "" && isCookieDomain(c.Domain) {
+		b = append(b, "; Domain="...)
+		b = append(b, c.Domain...)
+	}
+
+	if path := sanitizeCookiePath(c.Path); path != "" {
+		b = append(b, "; Path="...)
+		b = append(b, path...)
+	}
+
+	if c.Secure {
+		b = append(b, "; Secure"...)
+	}
+
+	if c.HttpOnly {
+		b = append(b, "; HttpOnly"...)
+	}
+
+	switch c.SameSite {
+	case SameSiteLax:
+		b = append(b, "; SameSite=Lax"...)
+	case SameSiteStrict:
+		b = append(b, "; SameSite=Strict"...)
+	case SameSiteNone:
+		b = append(b, "; SameSite=None"...)
+	}
+
+	if c.Partitioned {
+		b = append(b, "; Partitioned"...)
+	}
+
+	for _, ext := range c.Extensions {
+		if !isCookieExtension(ext) {
+			continue
+		}
+		b = append(b, "; "...)
+		b = append(b, ext...)
+	}
+
+	return string(b)
+}
+
+// Cookies returns the name/value pairs of all "Cookie" fields in the list.
+func (fs *Fields) Cookies() []Cookie {
+	return ParseCookies(*fs)
+}
+
+// AddCookie appends a name/value pair to the list's "Cookie" field, creating
+// the field if necessary. RFC 6265 section 5.4 forbids more than one "Cookie"
+// field per request, so pairs are always joined using "; ". Pairs with invalid
+// names are ignored, and invalid bytes are dropped from values.
+func (fs *Fields) AddCookie(name, value string) {
+	if name == "" || !isToken(name) {
+		return
+	}
+
+	pair := name + "=" + string(appendCookieValue(nil, value))
+
+	if i := fs.Index("Cookie", 0); i >= 0 && (*fs)[i].Value != "" {
+		(*fs)[i].Value += "; " + pair
+	} else if i >= 0 {
+		(*fs)[i].Value = pair
+	} else {
+		fs.Add("Cookie", pair)
+	}
+}
+
+// SetCookies parses every "Set-Cookie" field in the list. Unlike most fields,
+// "Set-Cookie" fields can't be combined into a single comma-separated value
+// (the "Expires" attribute may itself contain a comma), which is why each
+// field is parsed individually. Malformed fields are skipped.
+func (fs *Fields) SetCookies() []*SetCookie {
+	var cookies []*SetCookie
+
+	for _, f := range *fs {
+		if !f.Is("Set-Cookie") {
+			continue
+		}
+
+		if c, err := ParseSetCookie(f.Value); err == nil {
+			cookies = append(cookies, c)
+		}
+	}
+
+	return cookies
+}
+
+// AddSetCookie appends a new "Set-Cookie" field to the list. Existing
+// "Set-Cookie" fields are left alone. Cookies with invalid names are ignored.
+func (fs *Fields) AddSetCookie(c *SetCookie) {
+	if v := c.String(); v != "" {
+		fs.Add("Set-Cookie", v)
+	}
+}
+
+// Some user agents and servers use "-" rather than " " as the separator in
+// otherwise valid IMF-fixdates, or use four-digit years in RFC 850 dates.
+var cookieDateFormats = []string{
+	"Mon, 02-Jan-2006 15:04:05 GMT",
+	"Mon, 02-Jan-06 15:04:05 GMT",
+}
+
+func parseCookieDate(s string) (time.Time, bool) {
+	if t, ok := parseHTTPDate(s); ok {
+		return t, true
+	}
+
+	for _, layout := range cookieDateFormats {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, true
+		}
+	}
+
+	return time.Time{}, false
+}
+
+func parseCookiePair(s string) (name, value string, ok bool) {
+	i := strings.IndexByte(s, '=')
+	if i <= 0 {
+		return "", "", false
+	}
+
+	name, value = strtrim(s[:i]), strtrim(s[i+1:])
+	if name == "" || !isToken(name) {
+		return "", "", false
+	}
+
+	// Strip surrounding double quotes.
+	if len(value) >= 2 && value[0] == '"' && value[len(value)-1] == '"' {
+		value = value[1 : len(value)-1]
+	}
+
+	for i := 0; i < len(value); i++ {
+		if !isCookieOctet(value[i]) {
+			return "", "", false
+		}
+	}
+
+	return name, value, true
+}
+
+func appendCookieValue(b []byte, value string) []byte {
+	value = sanitize(value, isCookieOctet)
+
+	// Values containing spaces or commas are allowed by most user agents,
+	// but only when quoted.
+	if strings.ContainsAny(value, " ,") {
+		b = append(b, '"')
+		b = append(b, value...)
+		return append(b, '"')
+	}
+	return append(b, value...)
+}
+
+// isCookieOctet reports whether c may appear in a cookie value. In addition
+// to the cookie-octet set from RFC 6265 section 4.1.1, spaces and commas are
+// accepted as most user agents do.
+func isCookieOctet(c byte) bool {
+	return 0x20 <= c && c < 0x7f && c != '"' && c != ';' && c != '\\'
+}
+
+// sanitizeCookiePath drops bytes which may not appear in a "Path" attribute.
+func sanitizeCookiePath(path string) string {
+	return sanitize(path, func(c byte) bool {
+		return 0x20 <= c && c < 0x7f && c != ';'
+	})
+}
+
+// isCookieDomain reports whether s looks like a host name or IP address
+// suitable for a "Domain" attribute.
+func isCookieDomain(s string) bool {
+	if len(s) > 255 {
+		return false
+	}
+
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z', '0' <= c && c <= '9':
+		case c == '-' || c == '.' || c == '_' || c == ':':
+		default:
+			return false
+		}
+	}
+
+	return true
+}
+
+// isCookieExtension reports whether an extension attribute can be emitted
+// without introducing further attributes or breaking out of the field.
+func isCookieExtension(s string) bool {
+	if s == "" {
+		return false
+	}
+
+	for i := 0; i < len(s); i++ {
+		if c := s[i]; c < 0x20 || c >= 0x7f || c == ';' {
+			return false
+		}
+	}
+
+	return true
+}
+
+// sanitize returns s with all bytes not satisfying valid removed.
+func sanitize(s string, valid func(c byte) bool) string {
+	for i := 0; i < len(s); i++ {
+		if valid(s[i]) {
+			continue
+		}
+
+		b := make([]byte, 0, len(s))
+		b = append(b, s[:i]...)
+
+		for ; i < len(s); i++ {
+			if valid(s[i]) {
+				b = append(b, s[i])
+			}
+		}
+
+		return string(b)
+	}
+
+	return s
+}
@@ -0,0 +1,157 @@
+package heat
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+var parseCookiesTests = []struct {
+	in  Fields
+	out []Cookie
+}{
+	{Fields{}, nil},
+	{Fields{{"Cookie", "a=1"}}, []Cookie{{"a", "1"}}},
+	{Fields{{"Cookie", "a=1; b=\"2\";c=3"}}, []Cookie{{"a", "1"}, {"b", "2"}, {"c", "3"}}},
+	{Fields{{"Cookie", "a=1"}, {"cookie", "b=2"}}, []Cookie{{"a", "1"}, {"b", "2"}}},
+	{Fields{{"Cookie", "=1; a b=2; c=x;y; d=4"}}, []Cookie{{"c", "x"}, {"d", "4"}}},
+}
+
+func TestParseCookies(t *testing.T) {
+	for _, test := range parseCookiesTests {
+		out := ParseCookies(test.in)
+		if !reflect.DeepEqual(out, test.out) {
+			t.Errorf("ParseCookies(%q):", test.in)
+			t.Errorf("  got  %q", out)
+			t.Errorf("  want %q", test.out)
+		}
+	}
+}
+
+var setCookieTests = []struct {
+	in  string
+	out *SetCookie
+	str string
+}{
+	{
+		"id=a3fWa; Expires=Wed, 21 Oct 2015 07:28:00 GMT; Secure; HttpOnly",
+		&SetCookie{
+			Name:     "id",
+			Value:    "a3fWa",
+			Expires:  time.Date(2015, 10, 21, 7, 28, 0, 0, time.UTC),
+			Secure:   true,
+			HttpOnly: true,
+		},
+		"id=a3fWa; Expires=Wed, 21 Oct 2015 07:28:00 GMT; Secure; HttpOnly",
+	},
+	{
+		"sid=x; max-age=0; domain=.example.com; path=/app; samesite=strict; Partitioned; Priority=High",
+		&SetCookie{
+			Name:        "sid",
+			Value:       "x",
+			MaxAge:      -1,
+			Domain:      "example.com",
+			Path:        "/app",
+			SameSite:    SameSiteStrict,
+			Partitioned: true,
+			Extensions:  []string{"Priority=High"},
+		},
+		"sid=x; Max-Age=0; Domain=example.com; Path=/app; SameSite=Strict; Partitioned; Priority=High",
+	},
+	{
+		"q=\"a b\"; Max-Age=60; Max-Age=bogus",
+		&SetCookie{
+			Name:   "q",
+			Value:  "a b",
+			MaxAge: 60,
+		},
+		"q=\"a b\"; Max-Age=60",
+	},
+}
+
+func TestSetCookie(t *testing.T) {
+	for _, test := range setCookieTests {
+		out, err := ParseSetCookie(test.in)
+		if err != nil || !reflect.DeepEqual(out, test.out) {
+			t.Errorf("ParseSetCookie(%q):", test.in)
+			t.Errorf("  got  %+v, %v", out, err)
+			t.Errorf("  want %+v, <nil>", test.out)
+			continue
+		}
+
+		if str := out.String(); str != test.str {
+			t.Errorf("(%+v).String():", out)
+			t.Errorf("  got  %q", str)
+			t.Errorf("  want %q", test.str)
+		}
+	}
+
+	for _, in := range []string{"", "=x", "a b=c", "a=\\"} {
+		if _, err := ParseSetCookie(in); err != ErrInvalidCookie {
+			t.Errorf("ParseSetCookie(%q):", in)
+			t.Errorf("  got  %v", err)
+			t.Errorf("  want %v", ErrInvalidCookie)
+		}
+	}
+}
+
+func TestSetCookieFields(t *testing.T) {
+	var fs Fields
+
+	fs.AddSetCookie(&SetCookie{Name: "a", Value: "1", Expires: time.Date(2015, 10, 21, 7, 28, 0, 0, time.UTC)})
+	fs.AddSetCookie(&SetCookie{Name: "b", Value: "2"})
+
+	want := Fields{
+		{"Set-Cookie", "a=1; Expires=Wed, 21 Oct 2015 07:28:00 GMT"},
+		{"Set-Cookie", "b=2"},
+	}
+
+	if !reflect.DeepEqual(fs, want) {
+		t.Errorf("AddSetCookie:")
+		t.Errorf("  got  %q", fs)
+		t.Errorf("  want %q", want)
+	}
+
+	if cs := fs.SetCookies(); len(cs) != 2 || cs[0].Name != "a" || cs[1].Name != "b" {
+		t.Errorf("SetCookies: got %+v", cs)
+	}
+}
+
+var setCookieStringTests = []struct {
+	in  *SetCookie
+	out string
+}{
+	{&SetCookie{Name: "a", Value: "x; Domain=evil"}, `a="x Domain=evil"`},
+	{&SetCookie{Name: "a", Value: "\"x\"\r\nX-Evil: 1"}, `a="xX-Evil: 1"`},
+	{&SetCookie{Name: "a;b", Value: "x"}, ""},
+	{&SetCookie{Name: "a\r\n", Value: "x"}, ""},
+	{&SetCookie{Name: "a", Value: "x", Domain: "evil; Secure"}, "a=x"},
+	{&SetCookie{Name: "a", Value: "x", Path: "/p; Domain=evil\r\n"}, "a=x; Path=/p Domain=evil"},
+	{&SetCookie{Name: "a", Value: "x", Extensions: []string{"Priority=High", "x; Domain=evil", "y\r\nZ: 1"}}, "a=x; Priority=High"},
+}
+
+func TestSetCookieString(t *testing.T) {
+	for _, test := range setCookieStringTests {
+		if out := test.in.String(); out != test.out {
+			t.Errorf("(%+v).String():", test.in)
+			t.Errorf("  got  %q", out)
+			t.Errorf("  want %q", test.out)
+		}
+	}
+}
+
+func TestAddCookie(t *testing.T) {
+	var fs Fields
+
+	fs.AddCookie("a", "1")
+	fs.AddCookie("b;c", "2")
+	fs.AddCookie("d", "3; e=4")
+
+	want := Fields{{"Cookie", `a=1; d="3 e=4"`}}
+
+	if !reflect.DeepEqual(fs, want) {
+		t.Errorf("AddCookie:")
+		t.Errorf("  got  %q", fs)
+		t.Errorf("  want %q", want)
+	}
+}
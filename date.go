@@ -0,0 +1,225 @@
+package heat
+
+import (
+	"sync/atomic"
+	"time"
+
+	"github.com/erkl/xo"
+)
+
+// Length of an IMF-fixdate, e.g. "Sun, 06 Nov 1994 08:49:37 GMT".
+const httpDateLen = 29
+
+var shortDays = [7]string{"Sun", "Mon", "Tue", "Wed", "Thu", "Fri", "Sat"}
+var longDays = [7]string{"Sunday", "Monday", "Tuesday", "Wednesday", "Thursday", "Friday", "Saturday"}
+
+var months = [12]string{
+	"Jan", "Feb", "Mar", "Apr", "May", "Jun",
+	"Jul", "Aug", "Sep", "Oct", "Nov", "Dec",
+}
+
+// ParseHTTPDate parses an HTTP-date in any of the three formats listed in
+// RFC 7231 section 7.1.1.1: the preferred IMF-fixdate, and the obsolete
+// RFC 850 and asctime formats. Unlike time.Parse it doesn't allocate.
+func ParseHTTPDate(s string) (time.Time, error) {
+	if t, ok := parseHTTPDate(s); ok {
+		return t, nil
+	}
+	return time.Time{}, ErrInvalidDate
+}
+
+// FormatHTTPDate formats a timestamp as an IMF-fixdate.
+func FormatHTTPDate(t time.Time) string {
+	var buf [httpDateLen]byte
+	return string(buf[:putHTTPDate(buf[:], t)])
+}
+
+// AppendHTTPDate appends an IMF-fixdate representation of t to dst and
+// returns the extended buffer.
+func AppendHTTPDate(dst []byte, t time.Time) []byte {
+	var buf [httpDateLen]byte
+	return append(dst, buf[:putHTTPDate(buf[:], t)]...)
+}
+
+// WriteHTTPDate writes an IMF-fixdate representation of t to w.
+func WriteHTTPDate(w xo.Writer, t time.Time) error {
+	buf, err := w.Reserve(httpDateLen)
+	if err != nil {
+		return err
+	}
+
+	return w.Commit(putHTTPDate(buf, t))
+}
+
+type cachedDate struct {
+	unix  int64
+	value string
+}
+
+var currentDate atomic.Value
+
+// CurrentDate returns the current time formatted as an IMF-fixdate, suitable
+// for a response's "Date" field. The formatted value is cached and only
+// recomputed once per second.
+func CurrentDate() string {
+	now := time.Now()
+	sec := now.Unix()
+
+	if c, _ := currentDate.Load().(*cachedDate); c != nil && c.unix == sec {
+		return c.value
+	}
+
+	c := &cachedDate{sec, FormatHTTPDate(now)}
+	currentDate.Store(c)
+
+	return c.value
+}
+
+// putHTTPDate writes the IMF-fixdate representation of t to dst, which must
+// be at least httpDateLen bytes long.
+func putHTTPDate(dst []byte, t time.Time) int {
+	t = t.UTC()
+
+	year, month, day := t.Date()
+	hour, min, sec := t.Clock()
+
+	n := copy(dst[0:], shortDays[t.Weekday()])
+	n += copy(dst[n:], ", ")
+	n += put2(dst[n:], day)
+	n += copy(dst[n:], " ")
+	n += copy(dst[n:], months[month-1])
+	n += copy(dst[n:], " ")
+	n += put2(dst[n:], year/100)
+	n += put2(dst[n:], year%100)
+	n += copy(dst[n:], " ")
+	n += put2(dst[n:], hour)
+	n += copy(dst[n:], ":")
+	n += put2(dst[n:], min)
+	n += copy(dst[n:], ":")
+	n += put2(dst[n:], sec)
+	n += copy(dst[n:], " GMT")
+
+	return n
+}
+
+func put2(dst []byte, x int) int {
+	dst[0] = byte('0' + x/10)
+	dst[1] = byte('0' + x%10)
+	return 2
+}
+
+func parseHTTPDate(s string) (time.Time, bool) {
+	switch {
+	case len(s) == httpDateLen && s[3] == ',':
+		// IMF-fixdate: "Sun, 06 Nov 1994 08:49:37 GMT".
+		if !isDay(s[:3], &shortDays) || s[4] != ' ' || s[7] != ' ' || s[11] != ' ' || s[16] != ' ' || s[25:] != " GMT" {
+			return time.Time{}, false
+		}
+
+		day, ok1 := atoi2(s[5:7])
+		month, ok2 := parseMonth(s[8:11])
+		y1, ok3 := atoi2(s[12:14])
+		y2, ok4 := atoi2(s[14:16])
+
+		if !ok1 || !ok2 || !ok3 || !ok4 {
+			return time.Time{}, false
+		}
+
+		return makeDate(y1*100+y2, month, day, s[17:25])
+
+	case len(s) == 24 && s[3] == ' ':
+		// asctime: "Sun Nov  6 08:49:37 1994".
+		if !isDay(s[:3], &shortDays) || s[7] != ' ' || s[10] != ' ' || s[19] != ' ' {
+			return time.Time{}, false
+		}
+
+		month, ok1 := parseMonth(s[4:7])
+		y1, ok2 := atoi2(s[20:22])
+		y2, ok3 := atoi2(s[22:24])
+
+		// The day of the month is padded with a space rather than a zero.
+		day, ok4 := int(s[9]-'0'), s[9]-'0' <= 9
+		if s[8] != ' ' {
+			day, ok4 = atoi2(s[8:10])
+		}
+
+		if !ok1 || !ok2 || !ok3 || !ok4 {
+			return time.Time{}, false
+		}
+
+		return makeDate(y1*100+y2, month, day, s[11:19])
+
+	default:
+		// RFC 850: "Sunday, 06-Nov-94 08:49:37 GMT".
+		i := len(s) - 23
+		if i < 6 || s[i-1] != ',' || !isDay(s[:i-1], &longDays) {
+			return time.Time{}, false
+		}
+
+		s = s[i:]
+		if s[0] != ' ' || s[3] != '-' || s[7] != '-' || s[10] != ' ' || s[19:] != " GMT" {
+			return time.Time{}, false
+		}
+
+		day, ok1 := atoi2(s[1:3])
+		month, ok2 := parseMonth(s[4:7])
+		year, ok3 := atoi2(s[8:10])
+
+		if !ok1 || !ok2 || !ok3 {
+			return time.Time{}, false
+		}
+
+		// RFC 7231 section 7.1.1.1: two-digit years appearing to be more
+		// than 50 years in the future refer to the most recent matching
+		// year in the past.
+		now := time.Now().Year()
+		year += now - now%100
+		if year > now+50 {
+			year -= 100
+		}
+
+		return makeDate(year, month, day, s[11:19])
+	}
+}
+
+func makeDate(year int, month time.Month, day int, clock string) (time.Time, bool) {
+	if clock[2] != ':' || clock[5] != ':' {
+		return time.Time{}, false
+	}
+
+	hour, ok1 := atoi2(clock[0:2])
+	min, ok2 := atoi2(clock[3:5])
+	sec, ok3 := atoi2(clock[6:8])
+
+	if !ok1 || !ok2 || !ok3 || day < 1 || day > 31 || hour > 23 || min > 59 || sec > 60 {
+		return time.Time{}, false
+	}
+
+	return time.Date(year, month, day, hour, min, sec, 0, time.UTC), true
+}
+
+func atoi2(s string) (int, bool) {
+	a, b := s[0]-'0', s[1]-'0'
+	if a > 9 || b > 9 {
+		return 0, false
+	}
+	return int(a)*10 + int(b), true
+}
+
+func parseMonth(s string) (time.Month, bool) {
+	for i, m := range months {
+		if s == m {
+			return time.Month(i + 1), true
+		}
+	}
+	return 0, false
+}
+
+func isDay(s string, days *[7]string) bool {
+	for _, d := range days {
+		if s == d {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,61 @@
+package heat
+
+import (
+	"testing"
+	"time"
+)
+
+var parseHTTPDateTests = []struct {
+	in  string
+	out time.Time
+	ok  bool
+}{
+	{"Sun, 06 Nov 1994 08:49:37 GMT", time.Date(1994, 11, 6, 8, 49, 37, 0, time.UTC), true},
+	{"Sunday, 06-Nov-94 08:49:37 GMT", time.Date(1994, 11, 6, 8, 49, 37, 0, time.UTC), true},
+	{"Sun Nov  6 08:49:37 1994", time.Date(1994, 11, 6, 8, 49, 37, 0, time.UTC), true},
+	{"Sun Nov 16 08:49:37 1994", time.Date(1994, 11, 16, 8, 49, 37, 0, time.UTC), true},
+	{"", time.Time{}, false},
+	{"Sun, 06 Nov 1994 08:49:37 UTC", time.Time{}, false},
+	{"Sun, 06 Nov 1994 24:49:37 GMT", time.Time{}, false},
+	{"Xyz, 06 Nov 1994 08:49:37 GMT", time.Time{}, false},
+	{"Sun, 06 Foo 1994 08:49:37 GMT", time.Time{}, false},
+	{"Sunday, 06-Nov-94 08:49:37 PST", time.Time{}, false},
+	{"Sun Nov  x 08:49:37 1994", time.Time{}, false},
+}
+
+func TestParseHTTPDate(t *testing.T) {
+	for _, test := range parseHTTPDateTests {
+		out, ok := parseHTTPDate(test.in)
+		if !out.Equal(test.out) || ok != test.ok {
+			t.Errorf("parseHTTPDate(%q):", test.in)
+			t.Errorf("  got  %v, %v", out, ok)
+			t.Errorf("  want %v, %v", test.out, test.ok)
+		}
+	}
+}
+
+func TestFormatHTTPDate(t *testing.T) {
+	for _, tm := range []time.Time{
+		time.Date(1994, 11, 6, 8, 49, 37, 0, time.UTC),
+		time.Date(2015, 1, 1, 0, 0, 0, 0, time.FixedZone("X", 3600)),
+		time.Unix(0, 0),
+	} {
+		out := FormatHTTPDate(tm)
+		if want := tm.UTC().Format(time.RFC1123); out != want[:len(want)-3]+"GMT" {
+			t.Errorf("FormatHTTPDate(%v):", tm)
+			t.Errorf("  got  %q", out)
+			t.Errorf("  want %q", want)
+		}
+	}
+}
+
+func TestParseHTTPDateAllocs(t *testing.T) {
+	n := testing.AllocsPerRun(100, func() {
+		parseHTTPDate("Sun, 06 Nov 1994 08:49:37 GMT")
+		parseHTTPDate("Sunday, 06-Nov-94 08:49:37 GMT")
+		parseHTTPDate("Sun Nov  6 08:49:37 1994")
+	})
+	if n != 0 {
+		t.Errorf("parseHTTPDate: got %v allocations, want 0", n)
+	}
+}
@@ -0,0 +1,461 @@
+package heat
+
+import (
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/binary"
+	"hash"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Digest algorithms, in order of preference.
+var digestAlgorithms = []string{
+	"SHA-512-256",
+	"SHA-512-256-sess",
+	"SHA-256",
+	"SHA-256-sess",
+	"MD5",
+	"MD5-sess",
+}
+
+// The DigestClient type answers "Digest" challenges, as described in
+// RFC 7616. It keeps track of nonce counts, and is safe for concurrent use.
+type DigestClient struct {
+	Username string
+	Password string
+
+	// Use "auth-int" protection (which hashes the request body) when the
+	// server offers it.
+	AuthInt bool
+
+	mu sync.Mutex
+	nc map[string]uint32
+}
+
+// Authorize answers a 401 or 407 response to req by adding an
+// "Authorization" or "Proxy-Authorization" field (respectively) to req,
+// choosing the strongest supported "Digest" challenge. The body argument is
+// only used with "auth-int" protection, and should hold the full request
+// body.
+func (dc *DigestClient) Authorize(resp *Response, req *Request, body []byte) error {
+	challengeField, credentialsField := "WWW-Authenticate", "Authorization"
+	if resp.Status == 407 {
+		challengeField, credentialsField = "Proxy-Authenticate", "Proxy-Authorization"
+	}
+
+	challenges, err := resp.Fields.Challenges(challengeField)
+	if err != nil {
+		return err
+	}
+
+	var best *Challenge
+	var rank = len(digestAlgorithms)
+
+	for _, ch := range challenges {
+		if !strcaseeq(ch.Scheme, "Digest") {
+			continue
+		}
+
+		algorithm, ok := ch.Params.Get("algorithm")
+		if !ok {
+			algorithm = "MD5"
+		}
+
+		for i, a := range digestAlgorithms {
+			if strcaseeq(a, algorithm) && i < rank {
+				best, rank = ch, i
+			}
+		}
+	}
+
+	if best == nil {
+		return ErrUnsupportedDigest
+	}
+
+	creds, err := dc.Respond(best, req, body)
+	if err != nil {
+		return err
+	}
+
+	req.Fields.Set(credentialsField, creds.String())
+	return nil
+}
+
+// Respond computes credentials answering a single "Digest" challenge.
+func (dc *DigestClient) Respond(ch *Challenge, req *Request, body []byte) (*Credentials, error) {
+	realm, _ := ch.Params.Get("realm")
+	nonce, ok := ch.Params.Get("nonce")
+	if !ok {
+		return nil, ErrUnsupportedDigest
+	}
+
+	algorithm, ok := ch.Params.Get("algorithm")
+	if !ok {
+		algorithm = "MD5"
+	}
+
+	h, sess, ok := digestHash(algorithm)
+	if !ok {
+		return nil, ErrUnsupportedDigest
+	}
+
+	// Pick a quality of protection, preferring "auth" unless asked to use
+	// "auth-int" (or it's the only option).
+	var qop string
+
+	if v, ok := ch.Params.Get("qop"); ok {
+		var auth, authInt bool
+
+		splitList(v, func(s string) bool {
+			auth = auth || strcaseeq(s, "auth")
+			authInt = authInt || strcaseeq(s, "auth-int")
+			return true
+		})
+
+		switch {
+		case authInt && (dc.AuthInt || !auth):
+			qop = "auth-int"
+		case auth:
+			qop = "auth"
+		default:
+			return nil, ErrUnsupportedDigest
+		}
+	}
+
+	cnonce := randomBoundary()
+	nc := dc.nextCount(nonce)
+
+	username := dc.Username
+	userhash := false
+
+	if v, ok := ch.Params.Get("userhash"); ok && strcaseeq(v, "true") {
+		username = digestHex(h, dc.Username+":"+realm)
+		userhash = true
+	}
+
+	response := digestResponse(h, sess, dc.Username, realm, dc.Password, req.Method, req.URI, nonce, nc, cnonce, qop, body)
+
+	creds := &Credentials{Scheme: "Digest"}
+	creds.Params.Add("username", username)
+	creds.Params.Add("realm", realm)
+	creds.Params.Add("uri", req.URI)
+	creds.Params.Add("algorithm", algorithm)
+	creds.Params.Add("nonce", nonce)
+
+	if qop != "" {
+		creds.Params.Add("nc", nc)
+		creds.Params.Add("cnonce", cnonce)
+		creds.Params.Add("qop", qop)
+	}
+
+	creds.Params.Add("response", response)
+
+	if v, ok := ch.Params.Get("opaque"); ok {
+		creds.Params.Add("opaque", v)
+	}
+
+	if userhash {
+		creds.Params.Add("userhash", "true")
+	}
+
+	return creds, nil
+}
+
+func (dc *DigestClient) nextCount(nonce string) string {
+	dc.mu.Lock()
+	defer dc.mu.Unlock()
+
+	if dc.nc == nil {
+		dc.nc = make(map[string]uint32)
+	}
+
+	dc.nc[nonce]++
+
+	s := strconv.FormatUint(uint64(dc.nc[nonce]), 16)
+	return strings.Repeat("0", 8-len(s)) + s
+}
+
+// The DigestServer type issues "Digest" challenges and verifies responses to
+// them. Nonces are stateless (they carry a timestamp and an HMAC signature),
+// but nonce counts are tracked to prevent replay attacks.
+type DigestServer struct {
+	Realm string
+
+	// Algorithms to offer, in order of preference. Defaults to "SHA-256"
+	// followed by "MD5".
+	Algorithms []string
+
+	// Key used to sign nonces, which should be kept secret and be at least
+	// 32 bytes long.
+	Secret []byte
+
+	// How long a nonce remains valid. Defaults to five minutes.
+	NonceLifetime time.Duration
+
+	// Use "Proxy-Authorization" rather than "Authorization".
+	Proxy bool
+
+	// Password returns the password of a particular user, and false if the
+	// user doesn't exist.
+	Password func(username string) (string, bool)
+
+	mu sync.Mutex
+	nc map[string]uint32
+}
+
+// Challenges returns one challenge per configured algorithm, suitable for
+// a 401 (or 407) response's "WWW-Authenticate" (or "Proxy-Authenticate")
+// fields. The stale flag should be set when answering ErrStaleDigest.
+func (ds *DigestServer) Challenges(stale bool) []*Challenge {
+	nonce := ds.nonce(time.Now())
+
+	var challenges []*Challenge
+
+	for _, algorithm := range ds.algorithms() {
+		ch := &Challenge{Scheme: "Digest"}
+		ch.Params.Add("realm", ds.Realm)
+		ch.Params.Add("qop", "auth, auth-int")
+		ch.Params.Add("algorithm", algorithm)
+		ch.Params.Add("nonce", nonce)
+
+		if stale {
+			ch.Params.Add("stale", "true")
+		}
+
+		challenges = append(challenges, ch)
+	}
+
+	return challenges
+}
+
+// Verify checks the "Digest" credentials of a request, returning the
+// authenticated username. The body argument is only used with "auth-int"
+// protection, and should hold the full request body.
+//
+// If the credentials are correct but based on an expired nonce, the function
+// returns ErrStaleDigest, in which case the client should be sent a new
+// challenge with the stale flag set.
+func (ds *DigestServer) Verify(req *Request, body []byte) (string, error) {
+	field := "Authorization"
+	if ds.Proxy {
+		field = "Proxy-Authorization"
+	}
+
+	v, ok := req.Fields.Get(field)
+	if !ok {
+		return "", ErrDigestUnauthorized
+	}
+
+	creds, err := ParseCredentials(v)
+	if err != nil || !strcaseeq(creds.Scheme, "Digest") {
+		return "", ErrDigestUnauthorized
+	}
+
+	p := creds.Params
+
+	username, _ := p.Get("username")
+	realm, _ := p.Get("realm")
+	uri, _ := p.Get("uri")
+	nonce, _ := p.Get("nonce")
+	response, _ := p.Get("response")
+	qop, _ := p.Get("qop")
+	nc, _ := p.Get("nc")
+	cnonce, _ := p.Get("cnonce")
+
+	algorithm, ok := p.Get("algorithm")
+	if !ok {
+		algorithm = "MD5"
+	}
+
+	if realm != ds.Realm || uri != req.URI || !ds.offers(algorithm) {
+		return "", ErrDigestUnauthorized
+	}
+
+	// Challenges always offer a qop, and nonce counts (which are only sent
+	// along with one) are needed to detect replays, so legacy RFC 2069
+	// credentials without a qop are rejected.
+	if !strcaseeq(qop, "auth") && !strcaseeq(qop, "auth-int") {
+		return "", ErrDigestUnauthorized
+	}
+
+	h, sess, _ := digestHash(algorithm)
+
+	password, ok := ds.Password(username)
+	if !ok {
+		return "", ErrDigestUnauthorized
+	}
+
+	expected := digestResponse(h, sess, username, realm, password, req.Method, uri, nonce, nc, cnonce, strings.ToLower(qop), body)
+	if subtle.ConstantTimeCompare([]byte(expected), []byte(response)) != 1 {
+		return "", ErrDigestUnauthorized
+	}
+
+	issued, ok := ds.verifyNonce(nonce)
+	if !ok {
+		return "", ErrDigestUnauthorized
+	}
+
+	if time.Since(issued) > ds.lifetime() {
+		return "", ErrStaleDigest
+	}
+
+	// Reject replayed nonce counts.
+	n, err := strconv.ParseUint(nc, 16, 32)
+	if err != nil || !ds.advance(nonce, uint32(n), issued) {
+		return "", ErrDigestUnauthorized
+	}
+
+	return username, nil
+}
+
+func (ds *DigestServer) algorithms() []string {
+	if len(ds.Algorithms) == 0 {
+		return []string{"SHA-256", "MD5"}
+	}
+	return ds.Algorithms
+}
+
+func (ds *DigestServer) offers(algorithm string) bool {
+	for _, a := range ds.algorithms() {
+		if strcaseeq(a, algorithm) {
+			return true
+		}
+	}
+	return false
+}
+
+func (ds *DigestServer) lifetime() time.Duration {
+	if ds.NonceLifetime <= 0 {
+		return 5 * time.Minute
+	}
+	return ds.NonceLifetime
+}
+
+// nonce generates a nonce consisting of the time of issue, some random
+// bytes, and a signature.
+func (ds *DigestServer) nonce(t time.Time) string {
+	var buf [8 + 8 + 16]byte
+
+	binary.BigEndian.PutUint64(buf[0:8], uint64(t.UnixNano()))
+	if _, err := io.ReadFull(rand.Reader, buf[8:16]); err != nil {
+		panic(err)
+	}
+
+	mac := hmac.New(sha256.New, ds.Secret)
+	mac.Write(buf[:16])
+	copy(buf[16:], mac.Sum(nil))
+
+	return base64.RawURLEncoding.EncodeToString(buf[:])
+}
+
+func (ds *DigestServer) verifyNonce(nonce string) (time.Time, bool) {
+	buf, err := base64.RawURLEncoding.DecodeString(nonce)
+	if err != nil || len(buf) != 8+8+16 {
+		return time.Time{}, false
+	}
+
+	mac := hmac.New(sha256.New, ds.Secret)
+	mac.Write(buf[:16])
+
+	if !hmac.Equal(mac.Sum(nil)[:16], buf[16:]) {
+		return time.Time{}, false
+	}
+
+	return time.Unix(0, int64(binary.BigEndian.Uint64(buf[0:8]))), true
+}
+
+// advance records the use of a nonce count, returning false if it isn't
+// greater than every previously used count for the same nonce.
+func (ds *DigestServer) advance(nonce string, nc uint32, issued time.Time) bool {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+
+	if ds.nc == nil {
+		ds.nc = make(map[string]uint32)
+	}
+
+	if nc <= ds.nc[nonce] {
+		return false
+	}
+
+	// Forget about expired nonces every now and then.
+	if len(ds.nc) >= 1024 {
+		for n := range ds.nc {
+			if t, ok := ds.verifyNonce(n); !ok || time.Since(t) > ds.lifetime() {
+				delete(ds.nc, n)
+			}
+		}
+	}
+
+	ds.nc[nonce] = nc
+	return true
+}
+
+// digestHash maps an algorithm name to a hash function, and whether it's
+// a "-sess" variant.
+func digestHash(algorithm string) (func() hash.Hash, bool, bool) {
+	var sess bool
+
+	if n := len(algorithm); n > 5 && strcaseeq(algorithm[n-5:], "-sess") {
+		algorithm, sess = algorithm[:n-5], true
+	}
+
+	switch {
+	case strcaseeq(algorithm, "MD5"):
+		return md5.New, sess, true
+	case strcaseeq(algorithm, "SHA-256"):
+		return sha256.New, sess, true
+	case strcaseeq(algorithm, "SHA-512-256"):
+		return sha512.New512_256, sess, true
+	}
+
+	return nil, false, false
+}
+
+// digestResponse computes the "response" parameter as described in RFC 7616
+// section 3.4.1. An empty qop selects the legacy RFC 2069 computation.
+func digestResponse(h func() hash.Hash, sess bool, username, realm, password, method, uri, nonce, nc, cnonce, qop string, body []byte) string {
+	ha1 := digestHex(h, username+":"+realm+":"+password)
+	if sess {
+		ha1 = digestHex(h, ha1+":"+nonce+":"+cnonce)
+	}
+
+	a2 := method + ":" + uri
+	if qop == "auth-int" {
+		bh := h()
+		bh.Write(body)
+		a2 += ":" + hexString(bh.Sum(nil))
+	}
+
+	ha2 := digestHex(h, a2)
+
+	if qop == "" {
+		return digestHex(h, ha1+":"+nonce+":"+ha2)
+	}
+
+	return digestHex(h, ha1+":"+nonce+":"+nc+":"+cnonce+":"+qop+":"+ha2)
+}
+
+func digestHex(h func() hash.Hash, s string) string {
+	x := h()
+	io.WriteString(x, s)
+	return hexString(x.Sum(nil))
+}
+
+func hexString(b []byte) string {
+	buf := make([]byte, 2*len(b))
+	for i, c := range b {
+		buf[2*i+0] = hex[c>>4]
+		buf[2*i+1] = hex[c&15]
+	}
+	return string(buf)
+}
@@ -0,0 +1,146 @@
+package heat
+
+import (
+	"strings"
+	"testing"
+)
+
+// Test vectors from RFC 7616 section 3.9.1.
+var digestResponseTests = []struct {
+	algorithm string
+	out       string
+}{
+	{"MD5", "8ca523f5e9506fed4657c9700eebdbec"},
+	{"SHA-256", "753927fa0e85d155564e2e272a28d1802ca10daf4496794697cf8db5856cb6c1"},
+}
+
+func TestDigestResponse(t *testing.T) {
+	for _, test := range digestResponseTests {
+		h, sess, _ := digestHash(test.algorithm)
+
+		out := digestResponse(h, sess, "Mufasa", "http-auth@example.org", "Circle of Life",
+			"GET", "/dir/index.html", "7ypf/xlj9XXwfDPEoM4URrv/xwf94BcCAzFZH4GiTo0v",
+			"00000001", "f2/wE4q74E6zIJEtWaHKaf5wv/H5QzzpXusqGemxURZJ", "auth", nil)
+
+		if out != test.out {
+			t.Errorf("digestResponse(%s):", test.algorithm)
+			t.Errorf("  got  %s", out)
+			t.Errorf("  want %s", test.out)
+		}
+	}
+}
+
+func TestDigestRoundTrip(t *testing.T) {
+	server := &DigestServer{
+		Realm:      "test",
+		Algorithms: []string{"MD5", "SHA-512-256-sess"},
+		Secret:     []byte("0123456789abcdef0123456789abcdef"),
+		Password: func(username string) (string, bool) {
+			return "secret", username == "user"
+		},
+	}
+
+	client := &DigestClient{Username: "user", Password: "secret", AuthInt: true}
+
+	resp := NewResponse(401, ReasonPhrase(401))
+	for _, ch := range server.Challenges(false) {
+		resp.Fields.Add("WWW-Authenticate", ch.String())
+	}
+
+	body := []byte("hello")
+
+	for i := 0; i < 2; i++ {
+		req := &Request{Method: "POST", URI: "/x"}
+		if err := client.Authorize(resp, req, body); err != nil {
+			t.Fatalf("Authorize: %v", err)
+		}
+
+		if v, _ := req.Fields.Get("Authorization"); !strings.Contains(v, "algorithm=SHA-512-256-sess") || !strings.Contains(v, "qop=auth-int") {
+			t.Errorf("Authorize: unexpected credentials %q", v)
+		}
+
+		if user, err := server.Verify(req, body); user != "user" || err != nil {
+			t.Errorf("Verify: got %q, %v", user, err)
+		}
+
+		// Replaying the same nonce count must fail.
+		if _, err := server.Verify(req, body); err != ErrDigestUnauthorized {
+			t.Errorf("Verify (replay): got %v, want %v", err, ErrDigestUnauthorized)
+		}
+
+		// As must tampering with the body.
+		if _, err := server.Verify(req, []byte("bye")); err != ErrDigestUnauthorized {
+			t.Errorf("Verify (tampered): got %v, want %v", err, ErrDigestUnauthorized)
+		}
+	}
+}
+
+var digestQopTests = []struct {
+	qop     string
+	authInt bool
+	out     string
+}{
+	{"auth", false, "auth"},
+	{"auth", true, "auth"},
+	{"auth-int, auth", false, "auth"},
+	{"auth-int, auth", true, "auth-int"},
+	{"auth, auth-int", true, "auth-int"},
+	{"auth-int", false, "auth-int"},
+	{"", false, ""},
+}
+
+func TestDigestQop(t *testing.T) {
+	for _, test := range digestQopTests {
+		ch := &Challenge{Scheme: "Digest"}
+		ch.Params.Add("realm", "test")
+		ch.Params.Add("nonce", "abc")
+		if test.qop != "" {
+			ch.Params.Add("qop", test.qop)
+		}
+
+		client := &DigestClient{Username: "user", Password: "secret", AuthInt: test.authInt}
+
+		creds, err := client.Respond(ch, &Request{Method: "GET", URI: "/"}, nil)
+		if err != nil {
+			t.Errorf("Respond(%q, %v): %v", test.qop, test.authInt, err)
+			continue
+		}
+
+		if out, _ := creds.Params.Get("qop"); out != test.out {
+			t.Errorf("Respond(%q, %v):", test.qop, test.authInt)
+			t.Errorf("  got  %q", out)
+			t.Errorf("  want %q", test.out)
+		}
+	}
+}
+
+func TestDigestVerifyWithoutQop(t *testing.T) {
+	server := &DigestServer{
+		Realm:  "test",
+		Secret: []byte("0123456789abcdef0123456789abcdef"),
+		Password: func(username string) (string, bool) {
+			return "secret", username == "user"
+		},
+	}
+
+	// Answer the server's challenge as an RFC 2069 client would.
+	ch := server.Challenges(false)[0]
+	ch.Params.Remove("qop")
+
+	client := &DigestClient{Username: "user", Password: "secret"}
+	req := &Request{Method: "GET", URI: "/x"}
+
+	creds, err := client.Respond(ch, req, nil)
+	if err != nil {
+		t.Fatalf("Respond: %v", err)
+	}
+
+	req.Fields.Add("Authorization", creds.String())
+
+	// Without a nonce count, replays couldn't be detected.
+	for i := 0; i < 3; i++ {
+		if _, err := server.Verify(req, nil); err != ErrDigestUnauthorized {
+			t.Errorf("Verify #%d: got %v, want %v", i+1, err, ErrDigestUnauthorized)
+		}
+	}
+}
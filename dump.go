@@ -0,0 +1,216 @@
+package heat
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// DumpRequest returns the wire form of a request. If body is true, the
+// message body is included, and the request's Body is replaced so that it
+// can still be sent afterwards.
+//
+// A positive limit caps the number of body bytes read. Bodies longer than
+// that are cut off at the limit, which leaves the dump incomplete.
+func DumpRequest(req *Request, body bool, limit int) ([]byte, error) {
+	var size BodySize
+
+	if body {
+		var err error
+		if size, err = RequestBodySize(req); err != nil {
+			return nil, err
+		}
+	}
+
+	return dump(func(w *bufio.Writer) error {
+		return WriteRequestHeader(NewBufioWriter(w), req)
+	}, &req.Body, size, limit)
+}
+
+// DumpResponse returns the wire form of a response. The method of the
+// request the response is for is needed to determine whether it has a body.
+// The body and limit parameters work like those of DumpRequest.
+func DumpResponse(resp *Response, method string, body bool, limit int) ([]byte, error) {
+	var size BodySize
+
+	if body {
+		var err error
+		if size, err = ResponseBodySize(resp, method); err != nil {
+			return nil, err
+		}
+	}
+
+	return dump(func(w *bufio.Writer) error {
+		return WriteResponseHeader(NewBufioWriter(w), resp)
+	}, &resp.Body, size, limit)
+}
+
+func dump(header func(w *bufio.Writer) error, body *io.ReadCloser, size BodySize, limit int) ([]byte, error) {
+	var buf bytes.Buffer
+
+	w := bufio.NewWriter(&buf)
+
+	if err := header(w); err != nil {
+		return nil, err
+	}
+
+	if size != 0 && *body != nil {
+		data, complete, err := peekBody(body, size, limit)
+		if err != nil {
+			return nil, err
+		}
+
+		xw := NewBufioWriter(w)
+
+		switch {
+		case complete:
+			err = WriteBody(xw, bytes.NewReader(data), size)
+		case size == Chunked:
+			_, err = NewChunkedWriter(xw).Write(data)
+		default:
+			_, err = xw.Write(data)
+		}
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if err := w.Flush(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// peekBody reads up to limit bytes of a body (or the whole body, for fixed
+// size bodies), and replaces it with one replaying the same data. The second
+// return value reports whether the whole body was read.
+func peekBody(body *io.ReadCloser, size BodySize, limit int) ([]byte, bool, error) {
+	var r io.Reader = *body
+
+	max := int64(-1)
+	if size > 0 {
+		max = int64(size)
+	}
+	if limit > 0 && (max < 0 || int64(limit) < max) {
+		max = int64(limit)
+	}
+
+	// Read one byte past the limit to tell whether anything remains.
+	if max >= 0 {
+		r = io.LimitReader(r, max+1)
+	}
+
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, false, err
+	}
+
+	*body = &replayBody{
+		Reader: io.MultiReader(bytes.NewReader(data), *body),
+		body:   *body,
+	}
+
+	complete := true
+
+	if max >= 0 && int64(len(data)) > max {
+		data, complete = data[:max], false
+	}
+
+	// A fixed size body is only complete if it has the declared size.
+	if size > 0 && int64(len(data)) < int64(size) {
+		complete = false
+	}
+
+	return data, complete, nil
+}
+
+type replayBody struct {
+	io.Reader
+	body io.ReadCloser
+}
+
+func (rb *replayBody) Close() error {
+	return rb.body.Close()
+}
+
+// FormatRequest formats a request for human consumption, listing its
+// header along with how the body is framed and whether the connection will
+// be closed afterwards.
+func FormatRequest(req *Request) string {
+	var b strings.Builder
+
+	b.WriteString(req.Method + " " + req.URI + " HTTP/" + strconv.Itoa(req.Major) + "." + strconv.Itoa(req.Minor) + "\n")
+	formatFields(&b, req.Fields)
+
+	size, err := RequestBodySize(req)
+	formatFraming(&b, size, err, Closing(req.Major, req.Minor, req.Fields))
+
+	return b.String()
+}
+
+// FormatResponse works like FormatRequest, for responses. The method of the
+// request the response is for is needed to determine whether it has a body.
+func FormatResponse(resp *Response, method string) string {
+	var b strings.Builder
+
+	b.WriteString("HTTP/" + strconv.Itoa(resp.Major) + "." + strconv.Itoa(resp.Minor) + " " + strconv.Itoa(resp.Status) + " " + resp.Reason + "\n")
+	formatFields(&b, resp.Fields)
+
+	size, err := ResponseBodySize(resp, method)
+	formatFraming(&b, size, err, Closing(resp.Major, resp.Minor, resp.Fields))
+
+	return b.String()
+}
+
+func formatFields(b *strings.Builder, fields Fields) {
+	width := 0
+	for _, f := range fields {
+		if len(f.Name) > width {
+			width = len(f.Name)
+		}
+	}
+
+	for _, f := range fields {
+		b.WriteString("  " + f.Name + ":" + strings.Repeat(" ", width-len(f.Name)+1) + f.Value + "\n")
+	}
+}
+
+func formatFraming(b *strings.Builder, size BodySize, err error, closing bool) {
+	b.WriteString("\n")
+
+	if err != nil {
+		b.WriteString("  body:    invalid (" + err.Error() + ")\n")
+	} else {
+		b.WriteString("  body:    " + size.String() + "\n")
+	}
+
+	if closing {
+		b.WriteString("  closing: yes\n")
+	} else {
+		b.WriteString("  closing: no (keep-alive)\n")
+	}
+}
+
+// String describes the body size in words.
+func (n BodySize) String() string {
+	switch {
+	case n == 0:
+		return "none"
+	case n == 1:
+		return "1 byte"
+	case n > 0:
+		return strconv.FormatInt(int64(n), 10) + " bytes"
+	case n == Chunked:
+		return "chunked"
+	case n == Multipart:
+		return "multipart"
+	case n == Unbounded:
+		return "until connection close"
+	default:
+		return "invalid"
+	}
+}
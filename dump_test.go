@@ -0,0 +1,93 @@
+package heat
+
+import (
+	"io"
+	"strings"
+	"testing"
+)
+
+var dumpTests = []struct {
+	fields Fields
+	body   string
+	limit  int
+	want   string
+}{
+	{
+		Fields{{"Host", "example.com"}, {"Content-Length", "5"}},
+		"hello",
+		0,
+		"POST / HTTP/1.1\r\nHost: example.com\r\nContent-Length: 5\r\n\r\nhello",
+	},
+	{
+		Fields{{"Host", "example.com"}, {"Content-Length", "5"}},
+		"hello",
+		3,
+		"POST / HTTP/1.1\r\nHost: example.com\r\nContent-Length: 5\r\n\r\nhel",
+	},
+	{
+		Fields{{"Host", "example.com"}, {"Transfer-Encoding", "chunked"}},
+		"hello",
+		0,
+		"POST / HTTP/1.1\r\nHost: example.com\r\nTransfer-Encoding: chunked\r\n\r\n5\r\nhello\r\n0\r\n\r\n",
+	},
+	{
+		Fields{{"Host", "example.com"}, {"Transfer-Encoding", "chunked"}},
+		"hello",
+		2,
+		"POST / HTTP/1.1\r\nHost: example.com\r\nTransfer-Encoding: chunked\r\n\r\n2\r\nhe\r\n",
+	},
+}
+
+func TestDumpRequest(t *testing.T) {
+	for _, test := range dumpTests {
+		req := &Request{
+			Method: "POST",
+			URI:    "/",
+			Major:  1,
+			Minor:  1,
+			Fields: test.fields,
+			Body:   io.NopCloser(strings.NewReader(test.body)),
+		}
+
+		out, err := DumpRequest(req, true, test.limit)
+		if err != nil || string(out) != test.want {
+			t.Errorf("DumpRequest(%q, %d):", test.body, test.limit)
+			t.Errorf("  got  %q, %v", out, err)
+			t.Errorf("  want %q, %v", test.want, nil)
+		}
+
+		// The body must still be readable in full.
+		rest, err := io.ReadAll(req.Body)
+		if err != nil || string(rest) != test.body {
+			t.Errorf("DumpRequest(%q, %d) body:", test.body, test.limit)
+			t.Errorf("  got  %q, %v", rest, err)
+			t.Errorf("  want %q, %v", test.body, nil)
+		}
+	}
+}
+
+func TestFormatResponse(t *testing.T) {
+	resp := &Response{
+		Major:  1,
+		Minor:  0,
+		Status: 200,
+		Reason: "OK",
+		Fields: Fields{
+			{"Content-Type", "text/plain"},
+			{"Content-Length", "12"},
+		},
+	}
+
+	want := "HTTP/1.0 200 OK\n" +
+		"  Content-Type:   text/plain\n" +
+		"  Content-Length: 12\n" +
+		"\n" +
+		"  body:    12 bytes\n" +
+		"  closing: yes\n"
+
+	if got := FormatResponse(resp, "GET"); got != want {
+		t.Errorf("FormatResponse:")
+		t.Errorf("  got  %q", got)
+		t.Errorf("  want %q", want)
+	}
+}
@@ -8,17 +8,36 @@ var (
 	ErrRequestHeader  = errors.New("malformed request header")
 	ErrRequestVersion = errors.New("invalid or unsupported protocol version in request header")
 	ErrRequestNoHost  = errors.New("request missing Host header field")
+	ErrRequestHost    = errors.New("invalid or conflicting Host header field")
+	ErrRequestTarget  = errors.New("invalid request-target")
 
 	ErrResponseHeader  = errors.New("malformed response header")
 	ErrResponseVersion = errors.New("invalid or unsupported protocol version in response header")
 
+	ErrHeaderTooLarge = errors.New("header line or message header too large")
+
 	ErrInvalidChunkedEncoding = errors.New("invalid chunked encoding")
 	ErrInvalidContentLength   = errors.New("invalid content length")
 
 	ErrInvalidBodySize = errors.New("invalid body size")
 	ErrNilBody         = errors.New("unexpected nil body body")
 
+	ErrInvalidCookie = errors.New("invalid cookie")
+	ErrInvalidDate   = errors.New("invalid HTTP-date")
+	ErrInvalidAuth   = errors.New("invalid authentication field")
+
+	ErrUnsupportedDigest  = errors.New("no supported digest challenge")
+	ErrDigestUnauthorized = errors.New("invalid digest credentials")
+	ErrStaleDigest        = errors.New("stale digest nonce")
+
+	ErrInvalidForwarded = errors.New("invalid Forwarded field")
+
+	ErrInvalidRange        = errors.New("invalid range")
+	ErrTooManyRanges       = errors.New("too many ranges")
+	ErrRangeNotSatisfiable = errors.New("range not satisfiable")
+
 	// Internal errors.
 	errMalformedHeader = errors.New("malformed header")
 	errInvalidVersion  = errors.New("invalid version")
+	errInvalidCommit   = errors.New("commit exceeds reserved space")
 )
@@ -0,0 +1,69 @@
+package heat
+
+// parseETag splits an entity-tag into its opaque-tag (including the
+// surrounding double quotes) and weakness indicator.
+func parseETag(s string) (opaque string, weak bool, ok bool) {
+	s = strtrim(s)
+
+	if len(s) >= 2 && s[0] == 'W' && s[1] == '/' {
+		s, weak = s[2:], true
+	}
+
+	if len(s) < 2 || s[0] != '"' || s[len(s)-1] != '"' {
+		return "", false, false
+	}
+
+	for i := 1; i < len(s)-1; i++ {
+		if c := s[i]; c == '"' || c < 0x21 || c == 0x7f {
+			return "", false, false
+		}
+	}
+
+	return s, weak, true
+}
+
+// etagStrongMatch performs the strong comparison described in RFC 7232
+// section 2.3.2: both entity-tags must be strong and character-for-character
+// identical.
+func etagStrongMatch(a, b string) bool {
+	ao, aw, aok := parseETag(a)
+	bo, bw, bok := parseETag(b)
+	return aok && bok && !aw && !bw && ao == bo
+}
+
+// etagWeakMatch performs the weak comparison described in RFC 7232 section
+// 2.3.2: the opaque-tags must be identical, regardless of either entity-tag
+// being weak.
+func etagWeakMatch(a, b string) bool {
+	ao, _, aok := parseETag(a)
+	bo, _, bok := parseETag(b)
+	return aok && bok && ao == bo
+}
+
+// splitETags invokes fn with each element of a comma-separated list of
+// entity-tags. Commas inside opaque-tags are not treated as separators.
+func splitETags(s string, fn func(s string) bool) {
+	var quoted bool
+	var start int
+
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '"':
+			quoted = !quoted
+		case ',':
+			if quoted {
+				continue
+			}
+			if e := strtrim(s[start:i]); e != "" && e != "," {
+				if !fn(e) {
+					return
+				}
+			}
+			start = i + 1
+		}
+	}
+
+	if e := strtrim(s[start:]); e != "" {
+		fn(e)
+	}
+}
@@ -177,31 +177,26 @@ func writeHeader(w xo.Writer, fields Fields) error {
 	return err
 }
 
-func readHeader(r xo.Reader) (Fields, error) {
-	var fields Fields
-
+// readHeader reads header fields from r, appending them to fields. All
+// strings are allocated from a, which may be nil.
+func readHeader(r xo.Reader, fields Fields, a *arena) (Fields, error) {
 	for {
 		buf, err := xo.PeekTo(r, '\n', 0)
 		if err != nil {
 			return nil, err
 		}
 
-		if c := buf[0]; c == '\n' || (c == '\r' && len(buf) == 2) {
+		if isBlankLine(buf) {
 			if err := r.Consume(len(buf)); err != nil {
 				return nil, err
 			} else {
 				return fields, nil
 			}
-		} else if c == ' ' || c == '\t' {
-			// Because the loop below will consume all continuation lines,
-			// taking this branch must mean that the first fields field has
-			// leading whitespace, which is illegal.
-			return nil, errMalformedHeader
 		}
 
-		colon := bytes.IndexByte(buf, ':')
-		if colon == -1 {
-			return nil, errMalformedHeader
+		colon, err := fieldColon(buf)
+		if err != nil {
+			return nil, err
 		}
 
 		// Lines beginning with horizontal whitespace are continuations of
@@ -213,27 +208,28 @@ func readHeader(r xo.Reader) (Fields, error) {
 				return nil, err
 			}
 
+			// Peeking further may have moved the buffered data, so
+			// the line must be re-sliced from the latest peek.
 			if c := peek[off]; c == ' ' || c == '\t' {
 				buf = peek
 			} else {
+				buf = peek[:off]
 				break
 			}
 		}
 
-		// Trim the field's name and value. The shrinkValue call will modify
+		// Trim the field's name and value. The splitField call will modify
 		// buf in place, which is referencing the xo.Reader's internal storage.
 		// This isn't ideal, but it will only matter if the Consume call fails,
 		// which is impossible for correct xo.Readers.
-		name := shrinkName(buf[:colon])
-		if len(name) == 0 {
-			return nil, errMalformedHeader
+		name, value, err := splitField(buf, colon)
+		if err != nil {
+			return nil, err
 		}
 
-		value := shrinkValue(buf[colon+1:])
-
 		fields = append(fields, Field{
-			Name:  stringify(name),
-			Value: string(value),
+			Name:  a.stringify(name),
+			Value: a.string(value),
 		})
 
 		// Consume the bytes we just parsed.
@@ -243,6 +239,42 @@ func readHeader(r xo.Reader) (Fields, error) {
 	}
 }
 
+// isBlankLine reports whether a line (including its line break) is empty,
+// signaling the end of a header.
+func isBlankLine(line []byte) bool {
+	c := line[0]
+	return c == '\n' || (c == '\r' && len(line) == 2)
+}
+
+// fieldColon validates the first line of a header field, returning the
+// position of the colon separating its name and value.
+func fieldColon(line []byte) (int, error) {
+	// Because continuation lines are always read together with the line
+	// they continue, a leading whitespace character here means the first
+	// field of the header has leading whitespace, which is illegal.
+	if c := line[0]; c == ' ' || c == '\t' {
+		return 0, errMalformedHeader
+	}
+
+	colon := bytes.IndexByte(line, ':')
+	if colon == -1 {
+		return 0, errMalformedHeader
+	}
+
+	return colon, nil
+}
+
+// splitField trims the name and value of a complete header field, including
+// any continuation lines. The value is normalized in place.
+func splitField(buf []byte, colon int) (name, value []byte, err error) {
+	name = shrinkName(buf[:colon])
+	if len(name) == 0 {
+		return nil, nil, errMalformedHeader
+	}
+
+	return name, shrinkValue(buf[colon+1:]), nil
+}
+
 func shrinkName(buf []byte) []byte {
 	for len(buf) > 0 && buf[len(buf)-1] == ' ' {
 		buf = buf[:len(buf)-1]
@@ -0,0 +1,350 @@
+package heat
+
+import (
+	"net"
+	"strings"
+)
+
+// The Forwarded struct represents a single forwarded-element of
+// a "Forwarded" field, as described in RFC 7239. The For and By values are
+// nodes as described in section 6, e.g. "192.0.2.60", "[2001:db8::1]:8080",
+// "unknown" or an obfuscated identifier like "_hidden".
+type Forwarded struct {
+	For   string
+	By    string
+	Host  string
+	Proto string
+
+	// Unrecognized parameters, in order of appearance.
+	Extensions Fields
+}
+
+// ParseForwarded parses all "Forwarded" fields in a header, returning their
+// elements in order. The last element was added by the proxy closest to the
+// recipient.
+func ParseForwarded(fields Fields) ([]Forwarded, error) {
+	var list []Forwarded
+	var err error
+
+	for _, f := range fields {
+		if !f.Is("Forwarded") {
+			continue
+		}
+
+		splitList(f.Value, func(s string) bool {
+			var fwd Forwarded
+
+			if fwd, err = parseForwardedElement(s); err != nil {
+				return false
+			}
+
+			list = append(list, fwd)
+			return true
+		})
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return list, nil
+}
+
+func parseForwardedElement(s string) (Forwarded, error) {
+	var fwd Forwarded
+	var quoted bool
+	var start int
+
+	for i := 0; i <= len(s); i++ {
+		if i < len(s) {
+			switch c := s[i]; {
+			case c == '\\' && quoted:
+				i++
+				continue
+			case c == '"':
+				quoted = !quoted
+				continue
+			case c != ';' || quoted:
+				continue
+			}
+		}
+
+		pair := strtrim(s[start:i])
+		start = i + 1
+
+		if pair == "" || pair == ";" {
+			continue
+		}
+
+		eq := strings.IndexByte(pair, '=')
+		if eq <= 0 {
+			return Forwarded{}, ErrInvalidForwarded
+		}
+
+		name, value := pair[:eq], pair[eq+1:]
+		if !isToken(name) {
+			return Forwarded{}, ErrInvalidForwarded
+		}
+
+		if value != "" && value[0] == '"' {
+			value = unquote(value)
+		} else if !isToken(value) {
+			return Forwarded{}, ErrInvalidForwarded
+		}
+
+		switch {
+		case strcaseeq(name, "for"):
+			fwd.For = value
+		case strcaseeq(name, "by"):
+			fwd.By = value
+		case strcaseeq(name, "host"):
+			fwd.Host = value
+		case strcaseeq(name, "proto"):
+			fwd.Proto = value
+		default:
+			fwd.Extensions = append(fwd.Extensions, Field{name, value})
+		}
+	}
+
+	if quoted {
+		return Forwarded{}, ErrInvalidForwarded
+	}
+
+	return fwd, nil
+}
+
+// String serializes the element, quoting values when necessary.
+func (fwd *Forwarded) String() string {
+	var b []byte
+
+	add := func(name, value string) {
+		if value == "" {
+			return
+		}
+		if len(b) > 0 {
+			b = append(b, ';')
+		}
+		b = append(b, name...)
+		b = append(b, '=')
+		b = append(b, quoteIfNeeded(value)...)
+	}
+
+	add("for", fwd.For)
+	add("by", fwd.By)
+	add("host", fwd.Host)
+	add("proto", fwd.Proto)
+
+	for _, ext := range fwd.Extensions {
+		add(ext.Name, ext.Value)
+	}
+
+	return string(b)
+}
+
+// AddForwarded appends an element to the list's last "Forwarded" field,
+// or adds a new field if there is none.
+func (fs *Fields) AddForwarded(fwd Forwarded) {
+	for i := len(*fs) - 1; i >= 0; i-- {
+		if (*fs)[i].Is("Forwarded") {
+			(*fs)[i].Value += ", " + fwd.String()
+			return
+		}
+	}
+
+	fs.Add("Forwarded", fwd.String())
+}
+
+// ForwardedNode converts an address such as Request.Remote ("host:port",
+// or just a host) into the node syntax used by "Forwarded" fields, enclosing
+// IPv6 addresses in brackets.
+func ForwardedNode(addr string) string {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		host, port = addr, ""
+	}
+
+	if strings.IndexByte(host, ':') >= 0 {
+		host = "[" + host + "]"
+	}
+
+	if port != "" {
+		return host + ":" + port
+	}
+
+	return host
+}
+
+// splitNode splits a node into its name and port. IPv6 addresses are
+// returned without brackets.
+func splitNode(node string) (name, port string) {
+	if strings.HasPrefix(node, "[") {
+		if i := strings.IndexByte(node, ']'); i >= 0 {
+			name, node = node[1:i], node[i+1:]
+			if strings.HasPrefix(node, ":") {
+				port = node[1:]
+			}
+			return name, port
+		}
+	}
+
+	if i := strings.LastIndexByte(node, ':'); i >= 0 {
+		return node[:i], node[i+1:]
+	}
+
+	return node, ""
+}
+
+// The ProxyResolver type determines the original client address, scheme and
+// host of requests which have passed through trusted reverse proxies.
+type ProxyResolver struct {
+	// Networks of proxies whose forwarding fields are trusted.
+	Trusted []*net.IPNet
+
+	// Fall back to "X-Forwarded-For", "X-Forwarded-Proto" and
+	// "X-Forwarded-Host" when a request has no "Forwarded" field.
+	XForwarded bool
+}
+
+// NewProxyResolver constructs a ProxyResolver trusting proxies within the
+// networks given in CIDR notation. Bare IP addresses are also accepted.
+func NewProxyResolver(cidrs ...string) (*ProxyResolver, error) {
+	pr := new(ProxyResolver)
+
+	for _, s := range cidrs {
+		if strings.IndexByte(s, '/') < 0 {
+			if ip := net.ParseIP(s); ip != nil && ip.To4() != nil {
+				s += "/32"
+			} else {
+				s += "/128"
+			}
+		}
+
+		_, n, err := net.ParseCIDR(s)
+		if err != nil {
+			return nil, err
+		}
+
+		pr.Trusted = append(pr.Trusted, n)
+	}
+
+	return pr, nil
+}
+
+// The Origin struct describes the effective origin of a request.
+type Origin struct {
+	// The client's IP address, or "unknown" or an obfuscated identifier if
+	// that's all a trusted proxy revealed.
+	Client string
+
+	Scheme string
+	Host   string
+}
+
+// Resolve computes the effective origin of a request. Forwarding fields are
+// walked from the proxy closest to the server outward, stopping at the
+// first untrusted hop. Requests arriving directly from untrusted peers are
+// attributed to the peer itself.
+func (pr *ProxyResolver) Resolve(req *Request) Origin {
+	peer, _ := splitNode(ForwardedNode(req.Remote))
+
+	o := Origin{
+		Client: peer,
+		Scheme: req.Scheme,
+	}
+
+	o.Host, _ = req.Fields.Get("Host")
+
+	if !pr.trusted(o.Client) {
+		return o
+	}
+
+	if req.Fields.Has("Forwarded") {
+		list, err := ParseForwarded(req.Fields)
+		if err != nil {
+			return o
+		}
+
+		for i := len(list) - 1; i >= 0; i-- {
+			fwd := list[i]
+
+			if fwd.For != "" {
+				o.Client, _ = splitNode(fwd.For)
+			}
+			if fwd.Proto != "" {
+				o.Scheme = strings.ToLower(fwd.Proto)
+			}
+			if fwd.Host != "" {
+				o.Host = fwd.Host
+			}
+
+			if fwd.For == "" || !pr.trusted(o.Client) {
+				break
+			}
+		}
+
+		return o
+	}
+
+	if !pr.XForwarded {
+		return o
+	}
+
+	if v := lastElement(req.Fields, "X-Forwarded-Proto"); v != "" {
+		o.Scheme = strings.ToLower(v)
+	}
+
+	if v := lastElement(req.Fields, "X-Forwarded-Host"); v != "" {
+		o.Host = v
+	}
+
+	var chain []string
+
+	for _, f := range req.Fields {
+		if f.Is("X-Forwarded-For") {
+			for _, s := range strings.Split(f.Value, ",") {
+				if s = strings.TrimSpace(s); s != "" {
+					chain = append(chain, s)
+				}
+			}
+		}
+	}
+
+	for i := len(chain) - 1; i >= 0; i-- {
+		o.Client, _ = splitNode(ForwardedNode(chain[i]))
+		if !pr.trusted(o.Client) {
+			break
+		}
+	}
+
+	return o
+}
+
+func (pr *ProxyResolver) trusted(addr string) bool {
+	ip := net.ParseIP(addr)
+	if ip == nil {
+		return false
+	}
+
+	for _, n := range pr.Trusted {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// lastElement returns the last element of a comma-separated list spread
+// over any number of fields.
+func lastElement(fields Fields, name string) string {
+	for i := len(fields) - 1; i >= 0; i-- {
+		if fields[i].Is(name) {
+			v := fields[i].Value
+			if j := strings.LastIndexByte(v, ','); j >= 0 {
+				v = v[j+1:]
+			}
+			return strings.TrimSpace(v)
+		}
+	}
+	return ""
+}
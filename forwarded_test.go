@@ -0,0 +1,97 @@
+package heat
+
+import (
+	"reflect"
+	"testing"
+)
+
+var parseForwardedTests = []struct {
+	in  Fields
+	out []Forwarded
+	err error
+}{
+	{
+		Fields{{"Forwarded", `for="_gazonk"`}},
+		[]Forwarded{{For: "_gazonk"}},
+		nil,
+	},
+	{
+		Fields{{"Forwarded", `For="[2001:db8:cafe::17]:4711"`}},
+		[]Forwarded{{For: "[2001:db8:cafe::17]:4711"}},
+		nil,
+	},
+	{
+		Fields{{"Forwarded", `for=192.0.2.60;proto=http;by=203.0.113.43`}, {"Forwarded", `for=192.0.2.43, for=198.51.100.17;secret="a;b"`}},
+		[]Forwarded{
+			{For: "192.0.2.60", Proto: "http", By: "203.0.113.43"},
+			{For: "192.0.2.43"},
+			{For: "198.51.100.17", Extensions: Fields{{"secret", "a;b"}}},
+		},
+		nil,
+	},
+	{Fields{{"Forwarded", `for=[::1]`}}, nil, ErrInvalidForwarded},
+	{Fields{{"Forwarded", `for="x`}}, nil, ErrInvalidForwarded},
+	{Fields{{"Forwarded", `for`}}, nil, ErrInvalidForwarded},
+}
+
+func TestParseForwarded(t *testing.T) {
+	for _, test := range parseForwardedTests {
+		out, err := ParseForwarded(test.in)
+		if !reflect.DeepEqual(out, test.out) || err != test.err {
+			t.Errorf("ParseForwarded(%q):", test.in)
+			t.Errorf("  got  %+v, %v", out, err)
+			t.Errorf("  want %+v, %v", test.out, test.err)
+		}
+	}
+}
+
+func TestForwardedString(t *testing.T) {
+	var fs Fields
+
+	fs.AddForwarded(Forwarded{For: ForwardedNode("[2001:db8::1]:80"), Proto: "https"})
+	fs.AddForwarded(Forwarded{For: ForwardedNode("192.0.2.1"), Host: "example.com"})
+
+	want := Fields{{"Forwarded", `for="[2001:db8::1]:80";proto=https, for=192.0.2.1;host=example.com`}}
+	if !reflect.DeepEqual(fs, want) {
+		t.Errorf("AddForwarded:")
+		t.Errorf("  got  %q", fs)
+		t.Errorf("  want %q", want)
+	}
+}
+
+var resolveTests = []struct {
+	remote string
+	fields Fields
+	out    Origin
+}{
+	// Untrusted peers are taken at face value.
+	{"192.0.2.1:1234", Fields{{"Host", "a"}, {"Forwarded", "for=1.1.1.1;host=b"}}, Origin{"192.0.2.1", "http", "a"}},
+
+	// Walk trusted proxies from the right.
+	{"10.0.0.1:1234", Fields{{"Host", "a"}, {"Forwarded", "for=1.1.1.1, for=2.2.2.2;proto=https;host=b, for=10.0.0.2"}}, Origin{"2.2.2.2", "https", "b"}},
+	{"10.0.0.1:1234", Fields{{"Forwarded", "for=_hidden, for=10.0.0.2"}}, Origin{"_hidden", "http", ""}},
+	{"[fd00::1]:1234", Fields{{"Forwarded", `for="[2001:db8::1]:99"`}}, Origin{"2001:db8::1", "http", ""}},
+
+	// X-Forwarded-* fallback.
+	{"10.0.0.1:1234", Fields{{"X-Forwarded-For", "1.1.1.1, 2.2.2.2"}, {"X-Forwarded-For", "10.0.0.3"}, {"X-Forwarded-Proto", "HTTPS"}}, Origin{"2.2.2.2", "https", ""}},
+}
+
+func TestProxyResolver(t *testing.T) {
+	pr, err := NewProxyResolver("10.0.0.0/8", "fd00::1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pr.XForwarded = true
+
+	for _, test := range resolveTests {
+		req := &Request{Fields: test.fields, Scheme: "http", Remote: test.remote}
+
+		out := pr.Resolve(req)
+		if out != test.out {
+			t.Errorf("Resolve(%s, %q):", test.remote, test.fields)
+			t.Errorf("  got  %+v", out)
+			t.Errorf("  want %+v", test.out)
+		}
+	}
+}
@@ -0,0 +1,503 @@
+// Package har converts heat requests and responses to and from HAR 1.2
+// (HTTP Archive) entries, as exported by browser developer tools.
+//
+// See http://www.softwareishard.com/blog/har-12-spec/ for the format.
+package har
+
+import (
+	"bufio"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io"
+	"mime"
+	"net"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/erkl/heat"
+)
+
+var (
+	errNoLog      = errors.New("har: missing log")
+	errNoRequest  = errors.New("har: entry without request")
+	errNoResponse = errors.New("har: entry without response")
+	errEncoding   = errors.New("har: unsupported encoding")
+)
+
+// The HAR struct represents the top level object of a HAR file.
+type HAR struct {
+	Log *Log `json:"log"`
+}
+
+// The Log struct represents a HAR log.
+type Log struct {
+	Version string   `json:"version"`
+	Creator *Creator `json:"creator"`
+	Entries []*Entry `json:"entries"`
+}
+
+// The Creator struct describes the application which created a log.
+type Creator struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+// The Entry struct represents a single exchange.
+type Entry struct {
+	StartedDateTime time.Time `json:"startedDateTime"`
+	Time            float64   `json:"time"`
+	Request         *Request  `json:"request"`
+	Response        *Response `json:"response"`
+	Cache           struct{}  `json:"cache"`
+	Timings         *Timings  `json:"timings"`
+	ServerIPAddress string    `json:"serverIPAddress,omitempty"`
+	Connection      string    `json:"connection,omitempty"`
+}
+
+// The Request struct represents a request.
+type Request struct {
+	Method      string       `json:"method"`
+	URL         string       `json:"url"`
+	HTTPVersion string       `json:"httpVersion"`
+	Cookies     []*Cookie    `json:"cookies"`
+	Headers     []*NameValue `json:"headers"`
+	QueryString []*NameValue `json:"queryString"`
+	PostData    *PostData    `json:"postData,omitempty"`
+	HeadersSize int64        `json:"headersSize"`
+	BodySize    int64        `json:"bodySize"`
+}
+
+// The Response struct represents a response.
+type Response struct {
+	Status      int          `json:"status"`
+	StatusText  string       `json:"statusText"`
+	HTTPVersion string       `json:"httpVersion"`
+	Cookies     []*Cookie    `json:"cookies"`
+	Headers     []*NameValue `json:"headers"`
+	Content     *Content     `json:"content"`
+	RedirectURL string       `json:"redirectURL"`
+	HeadersSize int64        `json:"headersSize"`
+	BodySize    int64        `json:"bodySize"`
+}
+
+// The NameValue struct represents a header field or query parameter.
+type NameValue struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// The Cookie struct represents a cookie sent in a request, or set by
+// a response.
+type Cookie struct {
+	Name     string     `json:"name"`
+	Value    string     `json:"value"`
+	Path     string     `json:"path,omitempty"`
+	Domain   string     `json:"domain,omitempty"`
+	Expires  *time.Time `json:"expires,omitempty"`
+	HTTPOnly bool       `json:"httpOnly,omitempty"`
+	Secure   bool       `json:"secure,omitempty"`
+}
+
+// The PostData struct represents a request body. Binary bodies are base64
+// encoded, as indicated by Encoding (an extension to HAR 1.2, mirroring
+// Content).
+type PostData struct {
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text"`
+	Encoding string `json:"encoding,omitempty"`
+}
+
+// The Content struct represents a response body. Binary content is base64
+// encoded, as indicated by Encoding.
+type Content struct {
+	Size     int64  `json:"size"`
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text,omitempty"`
+	Encoding string `json:"encoding,omitempty"`
+}
+
+// The Timings struct breaks an exchange's duration down into phases, in
+// milliseconds. Phases which don't apply are -1.
+type Timings struct {
+	Blocked float64 `json:"blocked"`
+	DNS     float64 `json:"dns"`
+	Connect float64 `json:"connect"`
+	Send    float64 `json:"send"`
+	Wait    float64 `json:"wait"`
+	Receive float64 `json:"receive"`
+	SSL     float64 `json:"ssl"`
+}
+
+// NewLog constructs an empty log.
+func NewLog() *Log {
+	return &Log{
+		Version: "1.2",
+		Creator: &Creator{Name: "heat", Version: "1.0"},
+		Entries: []*Entry{},
+	}
+}
+
+// The Exchange struct holds everything needed to construct an entry. Bodies
+// are passed separately from the messages, as their Body readers can only be
+// consumed once.
+type Exchange struct {
+	Request     *heat.Request
+	RequestBody []byte
+
+	Response     *heat.Response
+	ResponseBody []byte
+
+	// Time at which the request was sent, and the time spent sending it,
+	// waiting for the response and receiving it.
+	Start   time.Time
+	Send    time.Duration
+	Wait    time.Duration
+	Receive time.Duration
+
+	// Address of the server, and an identifier for the connection.
+	ServerIP   string
+	Connection string
+}
+
+// NewEntry converts an exchange into a HAR entry.
+func NewEntry(ex *Exchange) (*Entry, error) {
+	if ex.Request == nil {
+		return nil, errNoRequest
+	}
+
+	if ex.Response == nil {
+		return nil, errNoResponse
+	}
+
+	req, err := newRequest(ex.Request, ex.RequestBody)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := newResponse(ex.Response, ex.ResponseBody)
+	if err != nil {
+		return nil, err
+	}
+
+	e := &Entry{
+		StartedDateTime: ex.Start,
+		Request:         req,
+		Response:        resp,
+		Timings: &Timings{
+			Blocked: -1,
+			DNS:     -1,
+			Connect: -1,
+			Send:    millis(ex.Send),
+			Wait:    millis(ex.Wait),
+			Receive: millis(ex.Receive),
+			SSL:     -1,
+		},
+		ServerIPAddress: ex.ServerIP,
+		Connection:      ex.Connection,
+	}
+
+	e.Time = e.Timings.Send + e.Timings.Wait + e.Timings.Receive
+
+	return e, nil
+}
+
+// Add converts an exchange into a HAR entry, and appends it to the log.
+func (l *Log) Add(ex *Exchange) error {
+	e, err := NewEntry(ex)
+	if err != nil {
+		return err
+	}
+
+	l.Entries = append(l.Entries, e)
+	return nil
+}
+
+// Write writes the log to w as a HAR file.
+func (l *Log) Write(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(&HAR{Log: l})
+}
+
+// ReadLog reads a HAR file.
+func ReadLog(r io.Reader) (*Log, error) {
+	var h HAR
+
+	if err := json.NewDecoder(r).Decode(&h); err != nil {
+		return nil, err
+	}
+
+	if h.Log == nil {
+		return nil, errNoLog
+	}
+
+	return h.Log, nil
+}
+
+func newRequest(req *heat.Request, body []byte) (*Request, error) {
+	u, err := req.ResolveURL()
+	if err != nil {
+		return nil, err
+	}
+
+	r := &Request{
+		Method:      req.Method,
+		URL:         u.String(),
+		HTTPVersion: httpVersion(req.Major, req.Minor),
+		Cookies:     []*Cookie{},
+		Headers:     headers(req.Fields),
+		QueryString: []*NameValue{},
+		HeadersSize: headerSize(func(w *bufio.Writer) error {
+			return heat.WriteRequestHeader(heat.NewBufioWriter(w), req)
+		}),
+		BodySize: int64(len(body)),
+	}
+
+	for _, c := range req.Fields.Cookies() {
+		r.Cookies = append(r.Cookies, &Cookie{Name: c.Name, Value: c.Value})
+	}
+
+	if query, err := req.ParseQuery(); err == nil {
+		names := make([]string, 0, len(query))
+		for name := range query {
+			names = append(names, name)
+		}
+
+		sort.Strings(names)
+
+		for _, name := range names {
+			for _, v := range query[name] {
+				r.QueryString = append(r.QueryString, &NameValue{name, v})
+			}
+		}
+	}
+
+	if len(body) > 0 {
+		ct, _ := req.Fields.Get("Content-Type")
+		r.PostData = &PostData{MimeType: ct}
+		r.PostData.Text, r.PostData.Encoding = encodeBody(ct, body)
+	}
+
+	return r, nil
+}
+
+func newResponse(resp *heat.Response, body []byte) (*Response, error) {
+	r := &Response{
+		Status:      resp.Status,
+		StatusText:  resp.Reason,
+		HTTPVersion: httpVersion(resp.Major, resp.Minor),
+		Cookies:     []*Cookie{},
+		Headers:     headers(resp.Fields),
+		Content:     &Content{Size: int64(len(body))},
+		HeadersSize: headerSize(func(w *bufio.Writer) error {
+			return heat.WriteResponseHeader(heat.NewBufioWriter(w), resp)
+		}),
+		BodySize: int64(len(body)),
+	}
+
+	r.RedirectURL, _ = resp.Fields.Get("Location")
+	r.Content.MimeType, _ = resp.Fields.Get("Content-Type")
+
+	for _, c := range resp.Fields.SetCookies() {
+		hc := &Cookie{
+			Name:     c.Name,
+			Value:    c.Value,
+			Path:     c.Path,
+			Domain:   c.Domain,
+			HTTPOnly: c.HttpOnly,
+			Secure:   c.Secure,
+		}
+
+		if !c.Expires.IsZero() {
+			t := c.Expires
+			hc.Expires = &t
+		}
+
+		r.Cookies = append(r.Cookies, hc)
+	}
+
+	if len(body) > 0 {
+		r.Content.Text, r.Content.Encoding = encodeBody(r.Content.MimeType, body)
+	}
+
+	return r, nil
+}
+
+// Requests reconstructs the requests of all entries in the log.
+func (l *Log) Requests() ([]*heat.Request, error) {
+	var list []*heat.Request
+
+	for _, e := range l.Entries {
+		req, err := e.HeatRequest()
+		if err != nil {
+			return nil, err
+		}
+
+		list = append(list, req)
+	}
+
+	return list, nil
+}
+
+// HeatRequest reconstructs the entry's request, ready to be sent to the
+// server it was captured from. The request's Remote field is set to the
+// server's address. HTTP/2 pseudo-header fields are dropped, and the
+// request is downgraded to HTTP/1.1 if necessary.
+func (e *Entry) HeatRequest() (*heat.Request, error) {
+	if e.Request == nil {
+		return nil, errNoRequest
+	}
+
+	u, err := url.Parse(e.Request.URL)
+	if err != nil {
+		return nil, err
+	}
+
+	req := &heat.Request{
+		Method: e.Request.Method,
+		URI:    u.RequestURI(),
+		Major:  1,
+		Minor:  1,
+		Scheme: u.Scheme,
+		Remote: remote(u),
+	}
+
+	if e.Request.HTTPVersion == "HTTP/1.0" {
+		req.Minor = 0
+	}
+
+	// The Host field goes first, and is replaced by any captured value.
+	req.Fields.Add("Host", u.Host)
+
+	for _, h := range e.Request.Headers {
+		// Skip HTTP/2 pseudo-header fields.
+		if strings.HasPrefix(h.Name, ":") {
+			continue
+		}
+
+		if strings.EqualFold(h.Name, "Host") {
+			req.Fields.Set("Host", h.Value)
+			continue
+		}
+
+		req.Fields.Add(h.Name, h.Value)
+	}
+
+	var body string
+	if pd := e.Request.PostData; pd != nil {
+		switch pd.Encoding {
+		case "":
+			body = pd.Text
+		case "base64":
+			b, err := base64.StdEncoding.DecodeString(pd.Text)
+			if err != nil {
+				return nil, err
+			}
+			body = string(b)
+		default:
+			return nil, errEncoding
+		}
+	}
+
+	// Browsers commonly leave out framing fields, or report those of
+	// HTTP/2, where they don't apply.
+	req.Fields.Remove("Transfer-Encoding")
+	req.Fields.Remove("Content-Length")
+
+	if body != "" || methodHasBody(req.Method) {
+		req.Fields.Add("Content-Length", strconv.Itoa(len(body)))
+	}
+
+	if body != "" {
+		req.Body = io.NopCloser(strings.NewReader(body))
+	}
+
+	return req, nil
+}
+
+func headers(fields heat.Fields) []*NameValue {
+	list := make([]*NameValue, len(fields))
+	for i, f := range fields {
+		list[i] = &NameValue{f.Name, f.Value}
+	}
+	return list
+}
+
+func headerSize(write func(w *bufio.Writer) error) int64 {
+	var cw countingWriter
+
+	w := bufio.NewWriter(&cw)
+	if write(w) != nil || w.Flush() != nil {
+		return -1
+	}
+
+	return cw.n
+}
+
+type countingWriter struct {
+	n int64
+}
+
+func (cw *countingWriter) Write(buf []byte) (int, error) {
+	cw.n += int64(len(buf))
+	return len(buf), nil
+}
+
+func httpVersion(major, minor int) string {
+	return "HTTP/" + strconv.Itoa(major) + "." + strconv.Itoa(minor)
+}
+
+func millis(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+// encodeBody returns body as text, base64 encoding it unless it's known to be
+// valid UTF-8 text.
+func encodeBody(contentType string, body []byte) (text, encoding string) {
+	if isText(contentType) && utf8.Valid(body) {
+		return string(body), ""
+	}
+
+	return base64.StdEncoding.EncodeToString(body), "base64"
+}
+
+func isText(contentType string) bool {
+	mt, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+
+	switch {
+	case strings.HasPrefix(mt, "text/"):
+		return true
+	case mt == "application/json", mt == "application/javascript", mt == "application/xml",
+		mt == "application/x-www-form-urlencoded":
+		return true
+	case strings.HasSuffix(mt, "+json"), strings.HasSuffix(mt, "+xml"):
+		return true
+	default:
+		return params["charset"] != ""
+	}
+}
+
+func remote(u *url.URL) string {
+	port := u.Port()
+	if port == "" {
+		if u.Scheme == "https" {
+			port = "443"
+		} else {
+			port = "80"
+		}
+	}
+
+	return net.JoinHostPort(u.Hostname(), port)
+}
+
+func methodHasBody(method string) bool {
+	return method == "POST" || method == "PUT" || method == "PATCH"
+}
@@ -0,0 +1,164 @@
+package har
+
+import (
+	"bytes"
+	"io"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/erkl/heat"
+)
+
+func TestRoundTrip(t *testing.T) {
+	req := &heat.Request{
+		Method: "POST",
+		URI:    "/search?q=heat&lang=en",
+		Major:  1,
+		Minor:  1,
+		Fields: heat.Fields{
+			{Name: "Host", Value: "example.com"},
+			{Name: "content-type", Value: "application/x-www-form-urlencoded"},
+			{Name: "Cookie", Value: "a=1; b=2"},
+			{Name: "Content-Length", Value: "7"},
+		},
+		Scheme: "https",
+	}
+
+	resp := heat.NewResponse(200, "OK")
+	resp.Fields.Add("Content-Type", "image/png")
+	resp.Fields.Add("Set-Cookie", "session=x; Path=/; HttpOnly")
+
+	l := NewLog()
+
+	err := l.Add(&Exchange{
+		Request:      req,
+		RequestBody:  []byte("x=1&y=2"),
+		Response:     resp,
+		ResponseBody: []byte{0x89, 'P', 'N', 'G'},
+		Start:        time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+		Send:         time.Millisecond,
+		Wait:         10 * time.Millisecond,
+		Receive:      2 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err := l.Write(&buf); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+
+	l, err = ReadLog(&buf)
+	if err != nil {
+		t.Fatalf("ReadLog: %v", err)
+	}
+
+	e := l.Entries[0]
+
+	if e.Request.URL != "https://example.com/search?q=heat&lang=en" || e.Time != 13 {
+		t.Errorf("entry: got URL %q and time %v", e.Request.URL, e.Time)
+	}
+
+	wantQuery := []*NameValue{{"lang", "en"}, {"q", "heat"}}
+	if !reflect.DeepEqual(e.Request.QueryString, wantQuery) {
+		t.Errorf("queryString: got %+v", e.Request.QueryString)
+	}
+
+	if len(e.Request.Cookies) != 2 || len(e.Response.Cookies) != 1 || !e.Response.Cookies[0].HTTPOnly {
+		t.Errorf("cookies: got %+v and %+v", e.Request.Cookies, e.Response.Cookies)
+	}
+
+	if c := e.Response.Content; c.Encoding != "base64" || c.Text != "iVBORw==" || c.Size != 4 {
+		t.Errorf("content: got %+v", c)
+	}
+
+	out, err := e.HeatRequest()
+	if err != nil {
+		t.Fatalf("HeatRequest: %v", err)
+	}
+
+	if !reflect.DeepEqual(out.Fields, req.Fields) || out.URI != req.URI || out.Remote != "example.com:443" {
+		t.Errorf("HeatRequest:")
+		t.Errorf("  got  %q %q %q", out.URI, out.Remote, out.Fields)
+		t.Errorf("  want %q %q %q", req.URI, "example.com:443", req.Fields)
+	}
+
+	if body, _ := io.ReadAll(out.Body); string(body) != "x=1&y=2" {
+		t.Errorf("HeatRequest: got body %q", body)
+	}
+}
+
+func TestBinaryRequestBody(t *testing.T) {
+	body := []byte{0x00, 0xff, 'a', 0x80, '\n'}
+
+	req := &heat.Request{
+		Method: "POST",
+		URI:    "/upload",
+		Major:  1,
+		Minor:  1,
+		Fields: heat.Fields{
+			{Name: "Host", Value: "example.com"},
+			{Name: "Content-Type", Value: "application/octet-stream"},
+			{Name: "Content-Length", Value: "5"},
+		},
+	}
+
+	l := NewLog()
+
+	if err := l.Add(&Exchange{Request: req, RequestBody: body, Response: heat.NewResponse(204, "No Content")}); err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err := l.Write(&buf); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+
+	l, err := ReadLog(&buf)
+	if err != nil {
+		t.Fatalf("ReadLog: %v", err)
+	}
+
+	if pd := l.Entries[0].Request.PostData; pd.Encoding != "base64" || pd.Text != "AP9hgAo=" {
+		t.Errorf("postData: got %+v", pd)
+	}
+
+	out, err := l.Entries[0].HeatRequest()
+	if err != nil {
+		t.Fatalf("HeatRequest: %v", err)
+	}
+
+	if got, _ := io.ReadAll(out.Body); !bytes.Equal(got, body) || !reflect.DeepEqual(out.Fields, req.Fields) {
+		t.Errorf("HeatRequest:")
+		t.Errorf("  got  %q %q", got, out.Fields)
+		t.Errorf("  want %q %q", body, req.Fields)
+	}
+}
+
+func TestNewEntryIncomplete(t *testing.T) {
+	req := &heat.Request{
+		Method: "GET",
+		URI:    "/",
+		Major:  1,
+		Minor:  1,
+		Fields: heat.Fields{{Name: "Host", Value: "example.com"}},
+	}
+
+	var tests = []struct {
+		ex  *Exchange
+		err error
+	}{
+		{&Exchange{Response: heat.NewResponse(200, "OK")}, errNoRequest},
+		{&Exchange{Request: req}, errNoResponse},
+	}
+
+	for _, test := range tests {
+		if _, err := NewEntry(test.ex); err != test.err {
+			t.Errorf("NewEntry(%+v):", test.ex)
+			t.Errorf("  got  %v", err)
+			t.Errorf("  want %v", test.err)
+		}
+	}
+}
@@ -0,0 +1,194 @@
+package heat
+
+// The IndexedFields type is an alternative to Fields for large headers, or
+// headers subject to many lookups. Fields are kept in their original order
+// and casing, alongside an index mapping each lowercase field name to the
+// positions of its fields. The zero value is an empty list, ready to use.
+type IndexedFields struct {
+	fields Fields
+	index  map[string]*[]int
+}
+
+// NewIndexedFields constructs an IndexedFields instance taking ownership of
+// fs, which must not be modified by the caller afterwards.
+func NewIndexedFields(fs Fields) *IndexedFields {
+	ix := &IndexedFields{
+		fields: fs,
+		index:  make(map[string]*[]int, len(fs)),
+	}
+
+	for i, f := range fs {
+		ix.insert(f.Name, i)
+	}
+
+	return ix
+}
+
+// Fields returns the underlying list of fields, without copying it. The list
+// must not be modified while the IndexedFields instance is in use.
+func (ix *IndexedFields) Fields() Fields {
+	return ix.fields
+}
+
+// Len returns the number of fields in the list.
+func (ix *IndexedFields) Len() int {
+	return len(ix.fields)
+}
+
+// Get returns the value of the first field matching the specified name.
+// The second return value indicates whether a match was found.
+func (ix *IndexedFields) Get(name string) (string, bool) {
+	if pos := ix.lookup(name); len(pos) > 0 {
+		return ix.fields[pos[0]].Value, true
+	} else {
+		return "", false
+	}
+}
+
+// Has returns true if the list contains a particular field.
+func (ix *IndexedFields) Has(name string) bool {
+	return len(ix.lookup(name)) > 0
+}
+
+// Index returns the index of the first field with the specified name,
+// starting the search at the index from.
+func (ix *IndexedFields) Index(name string, from int) int {
+	for _, i := range ix.lookup(name) {
+		if i >= from {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// Values returns the values of all fields with the specified name, in order.
+func (ix *IndexedFields) Values(name string) []string {
+	pos := ix.lookup(name)
+	if len(pos) == 0 {
+		return nil
+	}
+
+	values := make([]string, len(pos))
+	for j, i := range pos {
+		values[j] = ix.fields[i].Value
+	}
+
+	return values
+}
+
+// Add appends a field to the list.
+func (ix *IndexedFields) Add(name, value string) {
+	ix.fields = append(ix.fields, Field{name, value})
+	ix.insert(name, len(ix.fields)-1)
+}
+
+// Set adds a field to the list, returning true if any previous fields were
+// removed in the process.
+func (ix *IndexedFields) Set(name, value string) bool {
+	pos := ix.lookup(name)
+	if len(pos) == 0 {
+		ix.Add(name, value)
+		return false
+	}
+
+	ix.fields[pos[0]] = Field{name, value}
+
+	if len(pos) > 1 {
+		ix.drop(pos[1:])
+	}
+
+	return true
+}
+
+// Remove drops all fields with the specified name.
+func (ix *IndexedFields) Remove(name string) bool {
+	pos := ix.lookup(name)
+	if len(pos) == 0 {
+		return false
+	}
+
+	ix.drop(pos)
+	return true
+}
+
+// drop removes the fields at the specified (sorted) positions, then rebuilds
+// the index to reflect their new positions.
+func (ix *IndexedFields) drop(pos []int) {
+	var w, j int
+
+	for r, f := range ix.fields {
+		if j < len(pos) && pos[j] == r {
+			j++
+			continue
+		}
+
+		ix.fields[w] = f
+		w++
+	}
+
+	// Clear the tail so dropped strings can be garbage collected.
+	for i := w; i < len(ix.fields); i++ {
+		ix.fields[i] = Field{}
+	}
+
+	ix.fields = ix.fields[:w]
+
+	// Rebuild the index, reusing the existing position slices.
+	for _, list := range ix.index {
+		*list = (*list)[:0]
+	}
+
+	for i, f := range ix.fields {
+		ix.insert(f.Name, i)
+	}
+
+	for key, list := range ix.index {
+		if len(*list) == 0 {
+			delete(ix.index, key)
+		}
+	}
+}
+
+func (ix *IndexedFields) insert(name string, i int) {
+	if list := ix.list(name); list != nil {
+		*list = append(*list, i)
+	} else {
+		if ix.index == nil {
+			ix.index = make(map[string]*[]int)
+		}
+		ix.index[lowerString(name)] = &[]int{i}
+	}
+}
+
+func (ix *IndexedFields) lookup(name string) []int {
+	if list := ix.list(name); list != nil {
+		return *list
+	} else {
+		return nil
+	}
+}
+
+func (ix *IndexedFields) list(name string) *[]int {
+	var buf [64]byte
+
+	// Avoid allocating a lowercase key for the common case of short names.
+	if len(name) <= len(buf) {
+		return ix.index[string(lowerKey(buf[:], name))]
+	} else {
+		return ix.index[lowerString(name)]
+	}
+}
+
+// lowerKey writes a lowercase copy of name to buf, which must be large enough
+// to hold it.
+func lowerKey(buf []byte, name string) []byte {
+	for i := 0; i < len(name); i++ {
+		buf[i] = lowcase[name[i]]
+	}
+	return buf[:len(name)]
+}
+
+func lowerString(name string) string {
+	return string(lowerKey(make([]byte, len(name)), name))
+}
@@ -0,0 +1,84 @@
+package heat
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestIndexedFields(t *testing.T) {
+	ix := NewIndexedFields(Fields{
+		{"Host", "example.com"},
+		{"Accept", "text/html"},
+		{"Set-Cookie", "a=1"},
+		{"X-Trace", "1"},
+		{"set-cookie", "b=2"},
+	})
+
+	if v, ok := ix.Get("SET-COOKIE"); !ok || v != "a=1" {
+		t.Errorf("Get(%q):", "SET-COOKIE")
+		t.Errorf("  got  %q, %v", v, ok)
+		t.Errorf("  want %q, %v", "a=1", true)
+	}
+
+	if i := ix.Index("Set-Cookie", 3); i != 4 {
+		t.Errorf("Index(%q, 3):", "Set-Cookie")
+		t.Errorf("  got  %d", i)
+		t.Errorf("  want %d", 4)
+	}
+
+	if !ix.Remove("accept") || ix.Remove("accept") {
+		t.Errorf("Remove(%q): unexpected result", "accept")
+	}
+
+	if !ix.Set("Set-Cookie", "c=3") {
+		t.Errorf("Set(%q): expected previous fields to be removed", "Set-Cookie")
+	}
+
+	ix.Add("Accept", "*/*")
+
+	want := Fields{
+		{"Host", "example.com"},
+		{"Set-Cookie", "c=3"},
+		{"X-Trace", "1"},
+		{"Accept", "*/*"},
+	}
+
+	if out := ix.Fields(); !reflect.DeepEqual(out, want) {
+		t.Errorf("Fields():")
+		t.Errorf("  got  %q", out)
+		t.Errorf("  want %q", want)
+	}
+
+	for i, f := range want {
+		if j := ix.Index(f.Name, 0); j != i {
+			t.Errorf("Index(%q, 0):", f.Name)
+			t.Errorf("  got  %d", j)
+			t.Errorf("  want %d", i)
+		}
+	}
+
+	if ix.Has("Set-Cookie") && len(ix.Values("set-cookie")) != 1 {
+		t.Errorf("Values(%q): got %q", "set-cookie", ix.Values("set-cookie"))
+	}
+}
+
+func TestIndexedFieldsZero(t *testing.T) {
+	var ix IndexedFields
+
+	if ix.Has("Host") || ix.Remove("Host") || ix.Set("Host", "a") {
+		t.Errorf("empty IndexedFields: unexpected result")
+	}
+
+	ix.Add("Accept", "*/*")
+
+	want := Fields{{"Host", "a"}, {"Accept", "*/*"}}
+	if out := ix.Fields(); !reflect.DeepEqual(out, want) {
+		t.Errorf("Fields():")
+		t.Errorf("  got  %q", out)
+		t.Errorf("  want %q", want)
+	}
+
+	if v, ok := ix.Get("accept"); !ok || v != "*/*" {
+		t.Errorf("Get(%q): got %q, %v", "accept", v, ok)
+	}
+}
@@ -0,0 +1,341 @@
+// Package nethttp bridges heat and the standard library's net/http package,
+// converting between their request and response types, serving
+// http.Handlers over heat connections and implementing http.RoundTripper.
+//
+// The conversions are as faithful as the net/http types allow. What can't
+// be represented is lost:
+//
+//   - http.Header is a map, so field order and the casing of field names
+//     can't be represented. ToRequest records the original fields in the
+//     request's context, letting FromRequest restore their order and casing.
+//     Response field order is lost unless recorded by the caller.
+//   - The "Host", "Content-Length" and "Transfer-Encoding" fields are
+//     represented by dedicated http.Request and http.Response fields.
+//   - TLS connection details aren't known to heat; a Scheme of "https" is
+//     represented by an empty tls.ConnectionState.
+//   - Trailers aren't converted.
+package nethttp
+
+import (
+	"context"
+	"crypto/tls"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/erkl/heat"
+)
+
+type fieldsKey struct{}
+
+// OriginalFields returns the fields recorded in the context of a request
+// created by ToRequest.
+func OriginalFields(r *http.Request) (heat.Fields, bool) {
+	fields, ok := r.Context().Value(fieldsKey{}).(heat.Fields)
+	return fields, ok
+}
+
+// ToRequest converts a heat.Request into an http.Request, as it would have
+// been received by an http.Server. The request body is shared.
+func ToRequest(req *heat.Request) (*http.Request, error) {
+	return ToRequestContext(context.Background(), req)
+}
+
+// ToRequestContext is like ToRequest, but uses ctx as the base of the new
+// request's context.
+func ToRequestContext(ctx context.Context, req *heat.Request) (*http.Request, error) {
+	target, err := req.Target()
+	if err != nil {
+		return nil, err
+	}
+
+	var u *url.URL
+
+	if target.Form == heat.AuthorityForm {
+		u = &url.URL{Host: target.Authority}
+	} else if u, err = url.ParseRequestURI(req.URI); err != nil {
+		return nil, heat.ErrRequestTarget
+	}
+
+	size, err := heat.RequestBodySize(req)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &http.Request{
+		Method:     req.Method,
+		URL:        u,
+		Proto:      "HTTP/" + strconv.Itoa(req.Major) + "." + strconv.Itoa(req.Minor),
+		ProtoMajor: req.Major,
+		ProtoMinor: req.Minor,
+		Header:     FieldsToHeader(req.Fields),
+		Body:       req.Body,
+		RequestURI: req.URI,
+		RemoteAddr: req.Remote,
+	}
+
+	// Move framing and Host fields out of the header, like net/http does.
+	r.Host = r.Header.Get("Host")
+	if r.Host == "" {
+		r.Host = u.Host
+	}
+
+	r.Header.Del("Host")
+	r.Header.Del("Transfer-Encoding")
+
+	switch {
+	case size >= 0:
+		r.ContentLength = int64(size)
+	case size == heat.Chunked:
+		r.ContentLength = -1
+		r.TransferEncoding = []string{"chunked"}
+		r.Header.Del("Content-Length")
+	}
+
+	if r.Body == nil || r.ContentLength == 0 {
+		r.Body = http.NoBody
+	}
+
+	if req.Scheme == "https" {
+		r.TLS = &tls.ConnectionState{}
+	}
+
+	return r.WithContext(context.WithValue(ctx, fieldsKey{}, req.Fields)), nil
+}
+
+// FromRequest converts an http.Request, either received by an http.Server
+// or constructed by a client, into a heat.Request. The request body is
+// shared.
+func FromRequest(r *http.Request) (*heat.Request, error) {
+	uri := r.RequestURI
+	if uri == "" {
+		if r.URL == nil {
+			return nil, heat.ErrRequestTarget
+		} else if r.Method == "CONNECT" {
+			uri = r.URL.Host
+		} else {
+			uri = r.URL.RequestURI()
+		}
+	}
+
+	req := &heat.Request{
+		Method: r.Method,
+		URI:    uri,
+		Major:  r.ProtoMajor,
+		Minor:  r.ProtoMinor,
+		Scheme: "http",
+		Remote: r.RemoteAddr,
+	}
+
+	if req.Method == "" {
+		req.Method = "GET"
+	}
+
+	if req.Major == 0 && req.Minor == 0 {
+		req.Major, req.Minor = 1, 1
+	}
+
+	if r.TLS != nil || (r.URL != nil && r.URL.Scheme == "https") {
+		req.Scheme = "https"
+	}
+
+	original, _ := OriginalFields(r)
+
+	host := r.Host
+	if host == "" && r.URL != nil {
+		host = r.URL.Host
+	}
+
+	header := r.Header.Clone()
+	if header == nil {
+		header = make(http.Header)
+	}
+
+	if host != "" {
+		header.Set("Host", host)
+	}
+
+	// Restore framing fields.
+	hasBody := r.Body != nil && r.Body != http.NoBody
+
+	switch {
+	case chunked(r.TransferEncoding):
+		header.Del("Content-Length")
+		header.Set("Transfer-Encoding", "chunked")
+	case r.ContentLength > 0 || (r.ContentLength == 0 && header.Get("Content-Length") != ""):
+		header.Set("Content-Length", strconv.FormatInt(r.ContentLength, 10))
+	case (r.ContentLength < 0 || r.ContentLength == 0 && header.Get("Content-Length") == "") && hasBody:
+		// A zero ContentLength with a body means the length is unknown.
+		header.Set("Transfer-Encoding", "chunked")
+	}
+
+	req.Fields = HeaderToFields(header, original)
+
+	if hasBody {
+		req.Body = r.Body
+	}
+
+	return req, nil
+}
+
+// ToResponse converts a heat.Response into an http.Response, as it would
+// have been returned by an http.Client. The response body is shared. The
+// request is used to determine the size of the response body, and may be
+// nil for responses to GET requests.
+func ToResponse(resp *heat.Response, req *http.Request) (*http.Response, error) {
+	method := "GET"
+	if req != nil {
+		method = req.Method
+	}
+
+	size, err := heat.ResponseBodySize(resp, method)
+	if err != nil {
+		return nil, err
+	}
+
+	reason := resp.Reason
+	if reason == "" {
+		reason = heat.ReasonPhrase(resp.Status)
+	}
+
+	r := &http.Response{
+		Status:     strconv.Itoa(resp.Status) + " " + reason,
+		StatusCode: resp.Status,
+		Proto:      "HTTP/" + strconv.Itoa(resp.Major) + "." + strconv.Itoa(resp.Minor),
+		ProtoMajor: resp.Major,
+		ProtoMinor: resp.Minor,
+		Header:     FieldsToHeader(resp.Fields),
+		Body:       resp.Body,
+		Request:    req,
+		Close:      heat.Closing(resp.Major, resp.Minor, resp.Fields),
+	}
+
+	r.Header.Del("Transfer-Encoding")
+
+	switch {
+	case size >= 0:
+		r.ContentLength = int64(size)
+	case size == heat.Chunked:
+		r.ContentLength = -1
+		r.TransferEncoding = []string{"chunked"}
+		r.Header.Del("Content-Length")
+	default:
+		r.ContentLength = -1
+	}
+
+	if r.Body == nil || r.ContentLength == 0 {
+		r.Body = http.NoBody
+	}
+
+	return r, nil
+}
+
+// FromResponse converts an http.Response into a heat.Response. The response
+// body is shared.
+func FromResponse(r *http.Response) (*heat.Response, error) {
+	resp := &heat.Response{
+		Status: r.StatusCode,
+		Reason: strings.TrimPrefix(r.Status, strconv.Itoa(r.StatusCode)+" "),
+		Major:  r.ProtoMajor,
+		Minor:  r.ProtoMinor,
+	}
+
+	if resp.Reason == "" || resp.Reason == r.Status {
+		resp.Reason = heat.ReasonPhrase(r.StatusCode)
+	}
+
+	if resp.Major == 0 && resp.Minor == 0 {
+		resp.Major, resp.Minor = 1, 1
+	}
+
+	header := r.Header.Clone()
+	if header == nil {
+		header = make(http.Header)
+	}
+
+	hasBody := r.Body != nil && r.Body != http.NoBody
+
+	switch {
+	case chunked(r.TransferEncoding):
+		header.Del("Content-Length")
+		header.Set("Transfer-Encoding", "chunked")
+	case r.ContentLength >= 0 && header.Get("Content-Length") == "":
+		if hasBody || r.ContentLength > 0 {
+			header.Set("Content-Length", strconv.FormatInt(r.ContentLength, 10))
+		}
+	}
+
+	resp.Fields = HeaderToFields(header, nil)
+
+	if hasBody {
+		resp.Body = r.Body
+	}
+
+	return resp, nil
+}
+
+// FieldsToHeader converts a list of fields into an http.Header, which
+// canonicalizes field names.
+func FieldsToHeader(fields heat.Fields) http.Header {
+	h := make(http.Header, len(fields))
+
+	for _, f := range fields {
+		h.Add(f.Name, f.Value)
+	}
+
+	return h
+}
+
+// HeaderToFields converts an http.Header into a list of fields. Fields also
+// present in order (typically the list h was once converted from) are kept
+// in their original order and casing, followed by any remaining fields
+// sorted by name.
+func HeaderToFields(h http.Header, order heat.Fields) heat.Fields {
+	var fields heat.Fields
+
+	// Values not yet emitted, by canonical name.
+	pending := make(map[string][]string, len(h))
+	for name, values := range h {
+		pending[http.CanonicalHeaderKey(name)] = append(pending[http.CanonicalHeaderKey(name)], values...)
+	}
+
+	for _, f := range order {
+		key := http.CanonicalHeaderKey(f.Name)
+
+		if values := pending[key]; len(values) > 0 {
+			fields.Add(f.Name, values[0])
+			pending[key] = values[1:]
+		}
+	}
+
+	names := make([]string, 0, len(pending))
+	for name := range pending {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	// Keep the Host field first, as is customary.
+	sort.SliceStable(names, func(i, j int) bool {
+		return names[i] == "Host" && names[j] != "Host"
+	})
+
+	for _, name := range names {
+		for _, v := range pending[name] {
+			fields.Add(name, v)
+		}
+	}
+
+	return fields
+}
+
+func chunked(te []string) bool {
+	for _, s := range te {
+		if strings.EqualFold(s, "chunked") {
+			return true
+		}
+	}
+	return false
+}
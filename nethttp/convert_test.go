@@ -0,0 +1,140 @@
+package nethttp
+
+import (
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/erkl/heat"
+)
+
+func fields(pairs ...string) heat.Fields {
+	var fs heat.Fields
+	for i := 0; i < len(pairs); i += 2 {
+		fs.Add(pairs[i], pairs[i+1])
+	}
+	return fs
+}
+
+func TestRequestRoundTrip(t *testing.T) {
+	req := &heat.Request{
+		Method: "POST",
+		URI:    "/submit?x=1",
+		Major:  1,
+		Minor:  1,
+		Fields: fields(
+			"Host", "example.com",
+			"x-lower", "a",
+			"Content-Length", "5",
+			"Accept", "text/html",
+			"X-Lower", "b",
+		),
+		Body:   io.NopCloser(strings.NewReader("hello")),
+		Scheme: "https",
+		Remote: "192.0.2.1:1234",
+	}
+
+	r, err := ToRequest(req)
+	if err != nil {
+		t.Fatalf("ToRequest: %v", err)
+	}
+
+	if r.Host != "example.com" || r.URL.Path != "/submit" || r.ContentLength != 5 || r.TLS == nil || r.RemoteAddr != req.Remote {
+		t.Errorf("ToRequest: unexpected result %+v", r)
+	}
+
+	if v := r.Header.Values("X-Lower"); !reflect.DeepEqual(v, []string{"a", "b"}) {
+		t.Errorf("ToRequest: got X-Lower values %q", v)
+	}
+
+	// Modify the request the way a middleware might.
+	r.Header.Del("Accept")
+	r.Header.Set("X-Added", "1")
+
+	out, err := FromRequest(r)
+	if err != nil {
+		t.Fatalf("FromRequest: %v", err)
+	}
+
+	want := fields(
+		"Host", "example.com",
+		"x-lower", "a",
+		"Content-Length", "5",
+		"X-Lower", "b",
+		"X-Added", "1",
+	)
+
+	if !reflect.DeepEqual(out.Fields, want) {
+		t.Errorf("FromRequest:")
+		t.Errorf("  got  %q", out.Fields)
+		t.Errorf("  want %q", want)
+	}
+
+	if out.Method != req.Method || out.URI != req.URI || out.Scheme != "https" || out.Remote != req.Remote {
+		t.Errorf("FromRequest: unexpected result %+v", out)
+	}
+}
+
+func TestFromClientRequest(t *testing.T) {
+	r, _ := http.NewRequest("PUT", "http://example.com/a%20b", strings.NewReader("data"))
+	r.ContentLength = -1
+	r.Header.Set("User-Agent", "test")
+
+	out, err := FromRequest(r)
+	if err != nil {
+		t.Fatalf("FromRequest: %v", err)
+	}
+
+	want := fields(
+		"Host", "example.com",
+		"Transfer-Encoding", "chunked",
+		"User-Agent", "test",
+	)
+
+	if out.URI != "/a%20b" || !reflect.DeepEqual(out.Fields, want) {
+		t.Errorf("FromRequest:")
+		t.Errorf("  got  %q %q", out.URI, out.Fields)
+		t.Errorf("  want %q %q", "/a%20b", want)
+	}
+}
+
+func TestResponseRoundTrip(t *testing.T) {
+	resp := &heat.Response{
+		Status: 404,
+		Reason: "Gone Fishing",
+		Major:  1,
+		Minor:  1,
+		Fields: fields(
+			"Transfer-Encoding", "chunked",
+			"Content-Type", "text/plain",
+		),
+		Body: io.NopCloser(strings.NewReader("nope")),
+	}
+
+	r, err := ToResponse(resp, nil)
+	if err != nil {
+		t.Fatalf("ToResponse: %v", err)
+	}
+
+	if r.Status != "404 Gone Fishing" || r.ContentLength != -1 || !reflect.DeepEqual(r.TransferEncoding, []string{"chunked"}) {
+		t.Errorf("ToResponse: unexpected result %+v", r)
+	}
+
+	out, err := FromResponse(r)
+	if err != nil {
+		t.Fatalf("FromResponse: %v", err)
+	}
+
+	want := fields(
+		"Content-Type", "text/plain",
+		"Transfer-Encoding", "chunked",
+	)
+
+	if out.Reason != resp.Reason || !reflect.DeepEqual(out.Fields, want) {
+		t.Errorf("FromResponse:")
+		t.Errorf("  got  %q %q", out.Reason, out.Fields)
+		t.Errorf("  want %q %q", resp.Reason, want)
+	}
+}
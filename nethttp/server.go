@@ -0,0 +1,656 @@
+package nethttp
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/tls"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/erkl/heat"
+	"github.com/erkl/xo"
+)
+
+// Responses up to this size are buffered in full, so that they can be sent
+// with a "Content-Length" field rather than chunked.
+const defaultBufferSize = 4 << 10
+
+// Request headers may be this large by default.
+const defaultMaxHeaderBytes = 64 << 10
+
+// At most this much of an unread request body is discarded to keep
+// a connection alive after the handler has returned.
+const maxDrainSize = 256 << 10
+
+// The Server struct serves an http.Handler over connections read and written
+// with heat.
+type Server struct {
+	Handler http.Handler
+
+	// Size of the buffer used to decide between "Content-Length" and
+	// chunked responses. Defaults to 4 KiB.
+	BufferSize int
+
+	// Maximum size of a request header, including the Request-Line. Larger
+	// headers are answered with "431 Request Header Fields Too Large".
+	// Defaults to 64 KiB.
+	MaxHeaderBytes int
+
+	// Logger for errors recovered from handler panics. Defaults to the
+	// standard logger.
+	ErrorLog *log.Logger
+
+	// Maximum time to wait for a request header, counted from the opening
+	// of the connection or, for later requests, from the arrival of the
+	// header's first byte. Clients exceeding it get a "408 Request Timeout"
+	// response. Defaults to IdleTimeout; if both are zero there's no limit.
+	ReadHeaderTimeout time.Duration
+
+	// Maximum time to wait for the next request on a kept-alive connection.
+	// Defaults to ReadHeaderTimeout.
+	IdleTimeout time.Duration
+
+	// Minimum rate, in bytes per second, at which request bodies must
+	// arrive once a short grace period has passed. Only time spent waiting
+	// for data counts. Zero means no limit.
+	MinBodyRate int
+
+	// Maximum time to spend writing a response, counted from the end of the
+	// request header. Zero means no limit.
+	WriteTimeout time.Duration
+
+	// Optional function returning hooks to be invoked while reading requests
+	// from and writing responses to a new connection.
+	Trace func(conn net.Conn) *heat.Trace
+}
+
+// Serve accepts connections from l, serving each one in a new goroutine.
+func (s *Server) Serve(l net.Listener) error {
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			return err
+		}
+
+		go s.ServeConn(conn)
+	}
+}
+
+// ServeConn serves requests from a single connection until either side
+// closes it. The connection is closed before returning, unless a handler has
+// hijacked it.
+func (s *Server) ServeConn(conn net.Conn) error {
+	dc := &deadlineConn{Conn: conn}
+
+	// Every line of a header must fit in the read buffer.
+	br := bufio.NewReaderSize(dc, s.maxHeaderBytes()+1)
+	bw := bufio.NewWriter(dc)
+
+	lr := &limitReader{Reader: heat.NewBufioReader(br), n: -1}
+
+	var r xo.Reader = lr
+	var w = heat.NewBufioWriter(bw)
+
+	if s.Trace != nil {
+		if t := s.Trace(conn); t != nil {
+			r = heat.TraceReader(r, t)
+			w = heat.TraceWriter(w, t)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	ctx = context.WithValue(ctx, http.LocalAddrContextKey, conn.LocalAddr())
+
+	for first := true; ; first = false {
+		req, err := s.readRequest(dc, lr, r, first)
+		if err != nil {
+			switch err {
+			case io.EOF:
+				err = nil
+			case heat.ErrRequestHeader, heat.ErrRequestVersion:
+				s.reject(dc, w, 400)
+			case heat.ErrHeaderTooLarge, bufio.ErrBufferFull:
+				s.reject(dc, w, 431)
+			case ErrHeaderTimeout:
+				s.reject(dc, w, 408)
+			}
+
+			conn.Close()
+			return err
+		}
+
+		if s.WriteTimeout > 0 {
+			conn.SetWriteDeadline(time.Now().Add(s.WriteTimeout))
+		}
+
+		closing, hijacked, err := s.serve(ctx, dc, br, bw, r, w, req)
+		if hijacked {
+			return nil
+		}
+
+		if err != nil || closing {
+			conn.Close()
+			return err
+		}
+	}
+}
+
+// readRequest reads the next request header from a connection, applying the
+// idle and header timeouts.
+func (s *Server) readRequest(dc *deadlineConn, lr *limitReader, r xo.Reader, first bool) (*heat.Request, error) {
+	idle, header := s.IdleTimeout, s.ReadHeaderTimeout
+	if idle <= 0 {
+		idle = header
+	} else if header <= 0 {
+		header = idle
+	}
+
+	// Wait for the first byte of a follow-up request.
+	if !first && idle > 0 {
+		dc.setReadDeadline(time.Now().Add(idle), ErrIdleTimeout)
+		if _, err := r.Peek(1); err != nil {
+			return nil, err
+		}
+	}
+
+	if header > 0 {
+		dc.setReadDeadline(time.Now().Add(header), ErrHeaderTimeout)
+	}
+
+	lr.n = s.maxHeaderBytes()
+	req, err := heat.ReadRequestHeader(r)
+	lr.n = -1
+
+	if header > 0 {
+		dc.setReadDeadline(time.Time{}, nil)
+	}
+
+	return req, err
+}
+
+func (s *Server) maxHeaderBytes() int {
+	if s.MaxHeaderBytes <= 0 {
+		return defaultMaxHeaderBytes
+	}
+	return s.MaxHeaderBytes
+}
+
+func (s *Server) serve(ctx context.Context, dc *deadlineConn, br *bufio.Reader, bw *bufio.Writer, r xo.Reader, w xo.Writer, req *heat.Request) (closing, hijacked bool, err error) {
+	conn := dc.Conn
+
+	req.Remote = conn.RemoteAddr().String()
+	req.Scheme = "http"
+
+	tc, isTLS := conn.(*tls.Conn)
+	if isTLS {
+		req.Scheme = "https"
+	}
+
+	rw := &responseWriter{
+		conn:    conn,
+		bw:      bw,
+		br:      br,
+		w:       w,
+		req:     req,
+		header:  make(http.Header),
+		bufSize: s.BufferSize,
+		closing: heat.Closing(req.Major, req.Minor, req.Fields),
+	}
+
+	if rw.bufSize <= 0 {
+		rw.bufSize = defaultBufferSize
+	}
+
+	size, err := heat.RequestBodySize(req)
+	if err != nil {
+		s.reject(dc, w, 400)
+		return true, false, err
+	}
+
+	body, err := heat.OpenBody(r, size)
+	if err != nil {
+		s.reject(dc, w, 400)
+		return true, false, err
+	}
+
+	var rb *requestBody
+
+	if body != nil && s.MinBodyRate > 0 {
+		body = &rateReader{r: body, conn: dc, rate: s.MinBodyRate}
+	}
+
+	if body != nil {
+		rb = &requestBody{r: body}
+
+		// Don't ask for the body until the handler tries to read it.
+		if v, _ := req.Fields.Get("Expect"); strings.EqualFold(v, "100-continue") {
+			rb.expect = rw
+		}
+
+		req.Body = rb
+	}
+
+	hr, err := ToRequestContext(ctx, req)
+	if err != nil {
+		s.reject(dc, w, 400)
+		return true, false, err
+	}
+
+	if isTLS {
+		state := tc.ConnectionState()
+		hr.TLS = &state
+	}
+
+	if !s.call(rw, hr) {
+		return true, rw.hijacked, nil
+	}
+
+	if rw.hijacked {
+		return true, true, nil
+	}
+
+	if err := rw.finish(); err != nil {
+		return true, false, err
+	}
+
+	// Discard whatever the handler didn't read of the request body. If the
+	// client is still waiting for a "100 Continue", the body will never
+	// arrive and the connection can't be reused.
+	if rb != nil {
+		if rb.expect != nil {
+			return true, false, nil
+		}
+
+		if _, err := io.CopyN(io.Discard, body, maxDrainSize+1); err != io.EOF {
+			return true, false, nil
+		}
+	}
+
+	return rw.closing, false, nil
+}
+
+// call invokes the handler, recovering from panics.
+func (s *Server) call(rw *responseWriter, hr *http.Request) (ok bool) {
+	defer func() {
+		if v := recover(); v != nil {
+			if v != http.ErrAbortHandler {
+				s.logf("nethttp: panic serving %s: %v", hr.RemoteAddr, v)
+			}
+			ok = false
+		}
+	}()
+
+	s.Handler.ServeHTTP(rw, hr)
+	return true
+}
+
+func (s *Server) reject(dc *deadlineConn, w xo.Writer, status int) {
+	if s.WriteTimeout > 0 {
+		dc.SetWriteDeadline(time.Now().Add(s.WriteTimeout))
+	}
+
+	resp := heat.NewResponse(status, heat.ReasonPhrase(status))
+	resp.Fields.Add("Connection", "close")
+	resp.Fields.Add("Content-Length", "0")
+
+	if heat.WriteResponseHeader(w, resp) == nil {
+		w.Flush()
+	}
+}
+
+func (s *Server) logf(format string, args ...interface{}) {
+	if s.ErrorLog != nil {
+		s.ErrorLog.Printf(format, args...)
+	} else {
+		log.Printf(format, args...)
+	}
+}
+
+// The responseWriter type implements http.ResponseWriter, http.Flusher and
+// http.Hijacker on top of an xo.Writer.
+type responseWriter struct {
+	conn net.Conn
+	br   *bufio.Reader
+	bw   *bufio.Writer
+	w    xo.Writer
+
+	req    *heat.Request
+	header http.Header
+	status int
+
+	// Response body, buffered until it exceeds bufSize bytes or the handler
+	// flushes, at which point the header is written.
+	buf       []byte
+	bufSize   int
+	committed bool
+	headLen   int
+
+	// Body writer, once the header has been written.
+	body    io.Writer
+	chunked io.WriteCloser
+	fixed   *fixedWriter
+
+	closing  bool
+	hijacked bool
+	mu       sync.Mutex // Guards the 100 Continue response.
+}
+
+func (rw *responseWriter) Header() http.Header {
+	return rw.header
+}
+
+func (rw *responseWriter) WriteHeader(status int) {
+	if rw.status != 0 || rw.hijacked {
+		return
+	}
+
+	// Informational responses are sent right away.
+	if 100 <= status && status <= 199 && status != 101 {
+		resp := heat.NewResponse(status, heat.ReasonPhrase(status))
+		resp.Fields = HeaderToFields(rw.header, nil)
+
+		rw.mu.Lock()
+		defer rw.mu.Unlock()
+
+		if heat.WriteResponseHeader(rw.w, resp) == nil {
+			rw.w.Flush()
+		}
+
+		return
+	}
+
+	rw.status = status
+}
+
+func (rw *responseWriter) Write(p []byte) (int, error) {
+	if rw.hijacked {
+		return 0, http.ErrHijacked
+	}
+
+	if rw.status == 0 {
+		rw.WriteHeader(200)
+	}
+
+	if !bodyAllowed(rw.status) {
+		return 0, http.ErrBodyNotAllowed
+	}
+
+	// Responses to HEAD requests have no body, but the length of the body
+	// that would have been sent is still of interest.
+	if rw.req.Method == "HEAD" {
+		rw.headLen += len(p)
+		return len(p), nil
+	}
+
+	if !rw.committed {
+		if len(rw.buf)+len(p) <= rw.bufSize {
+			rw.buf = append(rw.buf, p...)
+			return len(p), nil
+		}
+
+		if err := rw.commit(false); err != nil {
+			return 0, err
+		}
+	}
+
+	return rw.body.Write(p)
+}
+
+func (rw *responseWriter) Flush() {
+	if rw.hijacked {
+		return
+	}
+
+	if rw.status == 0 {
+		rw.WriteHeader(200)
+	}
+
+	if !rw.committed {
+		if err := rw.commit(false); err != nil {
+			return
+		}
+	}
+
+	rw.w.Flush()
+}
+
+func (rw *responseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	if rw.hijacked {
+		return nil, nil, http.ErrHijacked
+	}
+
+	// Anything already written by the handler is flushed first.
+	if rw.committed {
+		if err := rw.w.Flush(); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	// Deadlines set by the server no longer apply.
+	rw.conn.SetDeadline(time.Time{})
+
+	rw.hijacked = true
+	return rw.conn, bufio.NewReadWriter(rw.br, rw.bw), nil
+}
+
+// commit writes the response header, choosing how to frame the body. If
+// final is true, the whole body has been buffered.
+func (rw *responseWriter) commit(final bool) error {
+	rw.committed = true
+
+	h := rw.header.Clone()
+	h.Del("Transfer-Encoding")
+
+	if h.Get("Date") == "" {
+		h.Set("Date", heat.CurrentDate())
+	}
+
+	rw.body = rw.w
+
+	switch cl := h.Get("Content-Length"); {
+	case !bodyAllowed(rw.status):
+		h.Del("Content-Length")
+
+	case cl != "":
+		n, err := strconv.ParseInt(cl, 10, 64)
+		if err != nil || n < 0 {
+			rw.closing = true
+			h.Del("Content-Length")
+		} else if rw.req.Method != "HEAD" {
+			rw.fixed = &fixedWriter{w: rw.w, n: n}
+			rw.body = rw.fixed
+		}
+
+	case final:
+		if rw.req.Method != "HEAD" {
+			h.Set("Content-Length", strconv.Itoa(len(rw.buf)))
+		} else if rw.headLen > 0 {
+			h.Set("Content-Length", strconv.Itoa(rw.headLen))
+		}
+
+	case rw.req.Method == "HEAD":
+		// There's no body to frame.
+
+	case rw.req.Major == 1 && rw.req.Minor >= 1:
+		h.Set("Transfer-Encoding", "chunked")
+		rw.chunked = heat.NewChunkedWriter(rw.w)
+		rw.body = rw.chunked
+
+	default:
+		// Older clients can only be told about the end of the body by
+		// closing the connection.
+		rw.closing = true
+	}
+
+	if rw.closing {
+		h.Set("Connection", "close")
+	}
+
+	resp := heat.NewResponse(rw.status, heat.ReasonPhrase(rw.status))
+	resp.Fields = HeaderToFields(h, nil)
+
+	// Handlers can ask for the connection to be closed, too.
+	if heat.Closing(resp.Major, resp.Minor, resp.Fields) {
+		rw.closing = true
+	}
+
+	rw.mu.Lock()
+	err := heat.WriteResponseHeader(rw.w, resp)
+	rw.mu.Unlock()
+
+	if err != nil {
+		return err
+	}
+
+	// Write whatever was buffered.
+	buf := rw.buf
+	rw.buf = nil
+
+	if len(buf) == 0 {
+		return nil
+	}
+
+	if final && rw.fixed == nil {
+		return heat.WriteBody(rw.w, bytes.NewReader(buf), heat.BodySize(len(buf)))
+	}
+
+	_, err = rw.body.Write(buf)
+	return err
+}
+
+// finish completes the response after the handler has returned.
+func (rw *responseWriter) finish() error {
+	if rw.status == 0 {
+		rw.WriteHeader(200)
+	}
+
+	if !rw.committed {
+		if err := rw.commit(true); err != nil {
+			return err
+		}
+	}
+
+	if rw.chunked != nil {
+		if err := rw.chunked.Close(); err != nil {
+			return err
+		}
+	}
+
+	// A body shorter than its declared length leaves the connection in an
+	// unusable state.
+	if rw.fixed != nil && rw.fixed.n > 0 {
+		rw.closing = true
+	}
+
+	return rw.w.Flush()
+}
+
+// continue100 sends a "100 Continue" response, unless the handler has
+// already started responding.
+func (rw *responseWriter) continue100() error {
+	rw.mu.Lock()
+	defer rw.mu.Unlock()
+
+	if rw.committed {
+		return nil
+	}
+
+	if err := heat.WriteResponseHeader(rw.w, heat.NewResponse(100, "Continue")); err != nil {
+		return err
+	}
+
+	return rw.w.Flush()
+}
+
+type requestBody struct {
+	r      io.Reader
+	expect *responseWriter
+	closed bool
+}
+
+func (rb *requestBody) Read(buf []byte) (int, error) {
+	if rb.closed {
+		return 0, http.ErrBodyReadAfterClose
+	}
+
+	if rb.expect != nil {
+		err := rb.expect.continue100()
+		rb.expect = nil
+
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	return rb.r.Read(buf)
+}
+
+func (rb *requestBody) Close() error {
+	rb.closed = true
+	return nil
+}
+
+// The limitReader type bounds the number of bytes which can be consumed while
+// reading a request header. A negative n means no limit.
+type limitReader struct {
+	xo.Reader
+	n int
+}
+
+func (lr *limitReader) Peek(n int) ([]byte, error) {
+	if lr.n >= 0 && n > lr.n {
+		return nil, heat.ErrHeaderTooLarge
+	}
+
+	buf, err := lr.Reader.Peek(n)
+	if lr.n >= 0 && len(buf) > lr.n {
+		buf = buf[:lr.n]
+	}
+
+	return buf, err
+}
+
+func (lr *limitReader) Read(buf []byte) (int, error) {
+	n, err := lr.Reader.Read(buf)
+	if lr.n >= 0 {
+		lr.n -= n
+	}
+	return n, err
+}
+
+func (lr *limitReader) Consume(n int) error {
+	if lr.n >= 0 {
+		lr.n -= n
+	}
+	return lr.Reader.Consume(n)
+}
+
+// The fixedWriter type enforces a declared "Content-Length".
+type fixedWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (fw *fixedWriter) Write(p []byte) (int, error) {
+	if int64(len(p)) > fw.n {
+		return 0, http.ErrContentLength
+	}
+
+	n, err := fw.w.Write(p)
+	fw.n -= int64(n)
+
+	return n, err
+}
+
+func bodyAllowed(status int) bool {
+	return !(100 <= status && status <= 199) && status != 204 && status != 304
+}
@@ -0,0 +1,281 @@
+package nethttp
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestServer(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Skipf("listen: %v", err)
+	}
+	defer l.Close()
+
+	s := &Server{
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body, _ := io.ReadAll(r.Body)
+
+			w.Header().Set("X-Method", r.Method)
+			w.Header().Set("X-Body", string(body))
+
+			switch r.URL.Path {
+			case "/small":
+				io.WriteString(w, "hello")
+			case "/large":
+				io.WriteString(w, strings.Repeat("x", 10000))
+			case "/flush":
+				io.WriteString(w, "a")
+				w.(http.Flusher).Flush()
+				io.WriteString(w, "b")
+			}
+		}),
+		BufferSize: 4096,
+	}
+
+	go s.Serve(l)
+
+	var tests = []struct {
+		method, path, body string
+		length             int64
+		chunked            bool
+		out                string
+	}{
+		{"GET", "/small", "", 5, false, "hello"},
+		{"GET", "/large", "", -1, true, strings.Repeat("x", 10000)},
+		{"GET", "/flush", "", -1, true, "ab"},
+		{"POST", "/small", "data", 5, false, "hello"},
+		{"HEAD", "/small", "", 5, false, ""},
+	}
+
+	client := &http.Client{}
+
+	for _, test := range tests {
+		var body io.Reader
+		if test.body != "" {
+			body = strings.NewReader(test.body)
+		}
+
+		req, _ := http.NewRequest(test.method, "http://"+l.Addr().String()+test.path, body)
+
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Errorf("%s %s: %v", test.method, test.path, err)
+			continue
+		}
+
+		out, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+
+		chunked := len(resp.TransferEncoding) > 0
+
+		if err != nil || string(out) != test.out || resp.ContentLength != test.length || chunked != test.chunked {
+			t.Errorf("%s %s:", test.method, test.path)
+			t.Errorf("  got  %q, %d, %v, %v", out, resp.ContentLength, chunked, err)
+			t.Errorf("  want %q, %d, %v, %v", test.out, test.length, test.chunked, nil)
+		}
+
+		if v := resp.Header.Get("X-Body"); v != test.body {
+			t.Errorf("%s %s: got X-Body %q, want %q", test.method, test.path, v, test.body)
+		}
+	}
+}
+
+func TestServerTimeouts(t *testing.T) {
+	defer func(d time.Duration) { bodyRateGrace = d }(bodyRateGrace)
+	bodyRateGrace = 50 * time.Millisecond
+
+	var bodyErr error
+
+	s := &Server{
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if _, bodyErr = io.ReadAll(r.Body); bodyErr == ErrBodyTooSlow {
+				w.WriteHeader(408)
+			}
+		}),
+		ReadHeaderTimeout: 50 * time.Millisecond,
+		IdleTimeout:       50 * time.Millisecond,
+		MinBodyRate:       1000,
+	}
+
+	var tests = []struct {
+		input  string
+		status string
+		err    error
+	}{
+		// The header never ends.
+		{"GET / HTTP/1.1\r\nHost: a\r\n", "HTTP/1.1 408 ", ErrHeaderTimeout},
+
+		// No second request arrives.
+		{"GET / HTTP/1.1\r\nHost: a\r\n\r\n", "HTTP/1.1 200 ", ErrIdleTimeout},
+
+		// The body stops after a single byte.
+		{"POST / HTTP/1.1\r\nHost: a\r\nContent-Length: 10\r\n\r\nx", "HTTP/1.1 408 ", nil},
+	}
+
+	for _, test := range tests {
+		client, server := net.Pipe()
+
+		done := make(chan error, 1)
+		go func() { done <- s.ServeConn(server) }()
+
+		go io.WriteString(client, test.input)
+
+		out, _ := io.ReadAll(client)
+		err := <-done
+		client.Close()
+
+		if !strings.HasPrefix(string(out), test.status) || err != test.err {
+			t.Errorf("ServeConn(%q):", test.input)
+			t.Errorf("  got  %q, %v", out, err)
+			t.Errorf("  want %q..., %v", test.status, test.err)
+		}
+	}
+
+	if bodyErr != ErrBodyTooSlow {
+		t.Errorf("body read: got %v, want %v", bodyErr, ErrBodyTooSlow)
+	}
+}
+
+func TestServerIdleTimeout(t *testing.T) {
+	// Without a ReadHeaderTimeout, the IdleTimeout also bounds headers.
+	s := &Server{
+		Handler:     http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}),
+		IdleTimeout: 50 * time.Millisecond,
+	}
+
+	var tests = []struct {
+		input  string
+		status string
+		err    error
+	}{
+		// The first header never ends.
+		{"GET / HTTP/1.1\r\nHost: a\r\n", "HTTP/1.1 408 ", ErrHeaderTimeout},
+
+		// The second header starts, but never ends.
+		{"GET / HTTP/1.1\r\nHost: a\r\n\r\nGET / HTTP/1.1\r\n", "HTTP/1.1 200 ", ErrHeaderTimeout},
+
+		// No second request arrives.
+		{"GET / HTTP/1.1\r\nHost: a\r\n\r\n", "HTTP/1.1 200 ", ErrIdleTimeout},
+	}
+
+	for _, test := range tests {
+		client, server := net.Pipe()
+
+		done := make(chan error, 1)
+		go func() { done <- s.ServeConn(server) }()
+
+		go io.WriteString(client, test.input)
+
+		// Don't hang if the header timeout never fires.
+		client.SetReadDeadline(time.Now().Add(5 * time.Second))
+
+		out, _ := io.ReadAll(client)
+		client.Close()
+		err := <-done
+
+		if !strings.HasPrefix(string(out), test.status) || err != test.err {
+			t.Errorf("ServeConn(%q):", test.input)
+			t.Errorf("  got  %q, %v", out, err)
+			t.Errorf("  want %q..., %v", test.status, test.err)
+		}
+	}
+}
+
+func TestServerConn(t *testing.T) {
+	s := &Server{
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch r.URL.Path {
+			case "/close":
+				w.Header().Set("Connection", "close")
+			case "/hijack":
+				conn, rw, err := w.(http.Hijacker).Hijack()
+				if err != nil {
+					return
+				}
+				rw.WriteString("hijacked")
+				rw.Flush()
+				conn.Close()
+			}
+		}),
+		MaxHeaderBytes: 8 << 10,
+	}
+
+	get := func(path, fields string) string {
+		return "GET " + path + " HTTP/1.1\r\nHost: a\r\n" + fields + "\r\n"
+	}
+
+	var tests = []struct {
+		input string
+		out   string
+	}{
+		// Handlers asking for the connection to be closed get their way.
+		{get("/close", "") + get("/", ""), "HTTP/1.1 200 "},
+
+		// Long lines are fine, as long as they fit within the limit.
+		{get("/", "Cookie: "+strings.Repeat("x", 6000)+"\r\nConnection: close\r\n"), "HTTP/1.1 200 "},
+		{get("/", "Cookie: "+strings.Repeat("x", 9000)+"\r\n"), "HTTP/1.1 431 "},
+		{get("/", strings.Repeat("X-Field: "+strings.Repeat("x", 100)+"\r\n", 100)), "HTTP/1.1 431 "},
+
+		// Hijacked connections are left to the handler.
+		{get("/hijack", ""), "hijacked"},
+	}
+
+	for _, test := range tests {
+		client, server := net.Pipe()
+
+		done := make(chan error, 1)
+		go func() { done <- s.ServeConn(server) }()
+
+		go io.WriteString(client, test.input)
+
+		out, _ := io.ReadAll(client)
+		<-done
+		client.Close()
+
+		// Only a single response is expected.
+		if !strings.HasPrefix(string(out), test.out) || strings.Count(string(out), "HTTP/1.1 ") > 1 {
+			t.Errorf("ServeConn(%.40q):", test.input)
+			t.Errorf("  got  %.200q", out)
+			t.Errorf("  want %q...", test.out)
+		}
+	}
+}
+
+func TestServerExpectContinue(t *testing.T) {
+	s := &Server{
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body, _ := io.ReadAll(r.Body)
+			w.Write(body)
+		}),
+	}
+
+	client, server := net.Pipe()
+	defer client.Close()
+
+	go s.ServeConn(server)
+
+	go io.WriteString(client, "POST / HTTP/1.1\r\nHost: a\r\nExpect: 100-continue\r\nContent-Length: 5\r\nConnection: close\r\n\r\n")
+
+	br := bufio.NewReader(client)
+
+	// The body must not be sent before the server asks for it.
+	line, err := br.ReadString('\n')
+	if err != nil || line != "HTTP/1.1 100 Continue\r\n" {
+		t.Fatalf("interim response: got %q, %v", line, err)
+	}
+
+	go io.WriteString(client, "hello")
+
+	out, _ := io.ReadAll(br)
+	if !strings.Contains(string(out), "HTTP/1.1 200 OK\r\n") || !strings.HasSuffix(string(out), "\r\n\r\nhello") {
+		t.Errorf("response:")
+		t.Errorf("  got  %q", out)
+		t.Errorf("  want %q", "...HTTP/1.1 200 OK\r\n...\r\n\r\nhello")
+	}
+}
@@ -0,0 +1,86 @@
+package nethttp
+
+import (
+	"errors"
+	"io"
+	"net"
+	"os"
+	"time"
+)
+
+// Errors reported when a Server's timeouts expire. Reads from a request body
+// which arrives too slowly fail with ErrBodyTooSlow, which handlers may
+// answer with "408 Request Timeout".
+var (
+	ErrHeaderTimeout = errors.New("nethttp: timeout reading request header")
+	ErrIdleTimeout   = errors.New("nethttp: idle connection timed out")
+	ErrBodyTooSlow   = errors.New("nethttp: request body arrived too slowly")
+	ErrWriteTimeout  = errors.New("nethttp: timeout writing response")
+)
+
+// Request bodies get this long before the minimum data rate kicks in.
+var bodyRateGrace = 5 * time.Second
+
+// The deadlineConn type replaces the errors of reads and writes which time
+// out with errors describing which timeout expired.
+type deadlineConn struct {
+	net.Conn
+
+	// Error reported by reads timing out, or nil to leave errors alone.
+	readErr error
+}
+
+// setReadDeadline sets the connection's read deadline, and the error to be
+// reported once it expires. A zero t clears the deadline.
+func (c *deadlineConn) setReadDeadline(t time.Time, err error) {
+	c.Conn.SetReadDeadline(t)
+	c.readErr = err
+}
+
+func (c *deadlineConn) Read(buf []byte) (int, error) {
+	n, err := c.Conn.Read(buf)
+	if c.readErr != nil && isTimeout(err) {
+		err = c.readErr
+	}
+	return n, err
+}
+
+func (c *deadlineConn) Write(buf []byte) (int, error) {
+	n, err := c.Conn.Write(buf)
+	if isTimeout(err) {
+		err = ErrWriteTimeout
+	}
+	return n, err
+}
+
+func isTimeout(err error) bool {
+	return err != nil && errors.Is(err, os.ErrDeadlineExceeded)
+}
+
+// The rateReader type enforces a minimum data rate on a request body. Only
+// time spent waiting for data counts, so handlers taking their time between
+// reads aren't held against the client.
+type rateReader struct {
+	r    io.Reader
+	conn *deadlineConn
+	rate int
+
+	n     int64
+	spent time.Duration
+}
+
+func (rr *rateReader) Read(buf []byte) (int, error) {
+	allowed := bodyRateGrace - rr.spent +
+		time.Duration(float64(rr.n)/float64(rr.rate)*float64(time.Second))
+
+	start := time.Now()
+
+	rr.conn.setReadDeadline(start.Add(allowed), ErrBodyTooSlow)
+	n, err := rr.r.Read(buf)
+	rr.conn.setReadDeadline(time.Time{}, nil)
+
+	rr.spent += time.Since(start)
+	rr.n += int64(n)
+
+	return n, err
+}
@@ -0,0 +1,492 @@
+package nethttp
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/erkl/heat"
+	"github.com/erkl/xo"
+)
+
+// WithFields returns a shallow copy of r, with its context recording the
+// exact fields to send. When sent by a Transport, the request's fields are
+// written in the same order and with the same casing as fields (see
+// HeaderToFields). This lets clients control details that http.Header can't
+// represent.
+func WithFields(r *http.Request, fields heat.Fields) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), fieldsKey{}, fields))
+}
+
+type traceKey struct{}
+
+// WithTrace returns a shallow copy of r, with its context carrying hooks to
+// be invoked by a Transport while sending it and reading the response.
+func WithTrace(r *http.Request, t *heat.Trace) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), traceKey{}, t))
+}
+
+func contextTrace(ctx context.Context) *heat.Trace {
+	t, _ := ctx.Value(traceKey{}).(*heat.Trace)
+	return t
+}
+
+// The Transport struct implements http.RoundTripper, writing requests and
+// reading responses with heat. Connections are kept alive and reused unless
+// either side asks for them to be closed.
+//
+// Proxies and protocols other than HTTP/1.x aren't supported.
+type Transport struct {
+	// Dial opens new connections. Defaults to a net.Dialer's DialContext.
+	Dial func(ctx context.Context, network, addr string) (net.Conn, error)
+
+	// Configuration for "https" connections. The ServerName is set to the
+	// request's host if empty.
+	TLSClientConfig *tls.Config
+
+	// Maximum number of idle connections kept per host. Defaults to 2.
+	MaxIdleConnsPerHost int
+
+	mu   sync.Mutex
+	idle map[string][]*persistConn
+}
+
+type persistConn struct {
+	conn net.Conn
+	r    xo.Reader
+	w    xo.Writer
+}
+
+var errUnsupportedScheme = errors.New("nethttp: unsupported protocol scheme")
+
+// RoundTrip implements http.RoundTripper.
+func (t *Transport) RoundTrip(r *http.Request) (*http.Response, error) {
+	if r.URL == nil || (r.URL.Scheme != "http" && r.URL.Scheme != "https") {
+		closeBody(r)
+		return nil, errUnsupportedScheme
+	}
+
+	req, err := FromRequest(r)
+	if err != nil {
+		closeBody(r)
+		return nil, err
+	}
+
+	// Requests constructed by clients have no RequestURI.
+	if r.RequestURI != "" {
+		closeBody(r)
+		return nil, errors.New("nethttp: RequestURI can't be set in client requests")
+	}
+
+	if r.Close && !heat.Closing(req.Major, req.Minor, req.Fields) {
+		req.Fields.Add("Connection", "close")
+	}
+
+	key := connKey(r.URL)
+
+	for {
+		pc, reused, err := t.getConn(r.Context(), r.URL, key)
+		if err != nil {
+			closeBody(r)
+			return nil, err
+		}
+
+		if trace := contextTrace(r.Context()); trace != nil && trace.GotConn != nil {
+			trace.GotConn(reused)
+		}
+
+		resp, err := t.roundTrip(pc, key, r, req)
+		if err == nil {
+			return resp, nil
+		}
+
+		// A reused connection may have been closed by the server while it
+		// was idle. Retry requests without bodies on a fresh connection.
+		if reused && replayable(req) && r.Context().Err() == nil {
+			continue
+		}
+
+		closeBody(r)
+		return nil, err
+	}
+}
+
+func (t *Transport) roundTrip(pc *persistConn, key string, r *http.Request, req *heat.Request) (*http.Response, error) {
+	ctx := r.Context()
+
+	// Abort the exchange by closing the connection if the request's context
+	// is canceled.
+	stop := context.AfterFunc(ctx, func() {
+		pc.conn.Close()
+	})
+
+	// Report progress through the request's trace, if it has one.
+	rd, wr := pc.r, pc.w
+
+	if trace := contextTrace(ctx); trace != nil {
+		rd = heat.TraceReader(rd, trace)
+		wr = heat.TraceWriter(wr, trace)
+	}
+
+	fail := func(err error) (*http.Response, error) {
+		stop()
+		pc.conn.Close()
+
+		if ctx.Err() != nil {
+			err = ctx.Err()
+		}
+
+		return nil, err
+	}
+
+	size, err := heat.RequestBodySize(req)
+	if err != nil {
+		return fail(err)
+	}
+
+	if err := heat.WriteRequestHeader(wr, req); err != nil {
+		return fail(err)
+	}
+
+	if err := heat.WriteBody(wr, req.Body, size); err != nil {
+		return fail(err)
+	}
+
+	if err := pc.w.Flush(); err != nil {
+		return fail(err)
+	}
+
+	closeBody(r)
+
+	// Skip interim responses.
+	var resp *heat.Response
+
+	for {
+		if resp, err = heat.ReadResponseHeader(rd); err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return fail(err)
+		}
+
+		if resp.Status < 100 || resp.Status > 199 || resp.Status == 101 {
+			break
+		}
+	}
+
+	hr, err := ToResponse(resp, r)
+	if err != nil {
+		return fail(err)
+	}
+
+	closing := heat.Closing(req.Major, req.Minor, req.Fields) || heat.Closing(resp.Major, resp.Minor, resp.Fields)
+
+	bodySize, err := heat.ResponseBodySize(resp, req.Method)
+	if err != nil {
+		return fail(err)
+	}
+
+	// Connections can only be reused after bodies of known length.
+	if bodySize == heat.Unbounded || resp.Status == 101 {
+		closing = true
+	}
+
+	hr.Close = closing
+	hr.Trailer = declaredTrailers(resp.Fields)
+
+	body := &responseBody{
+		t:       t,
+		pc:      pc,
+		key:     key,
+		closing: closing,
+		stop:    stop,
+		trailer: &hr.Trailer,
+	}
+
+	if resp.Status == 101 {
+		// The connection now belongs to the caller.
+		stop()
+		hr.Body = &switchedBody{pc}
+		return hr, nil
+	}
+
+	if body.r, err = heat.OpenBodyTrailers(rd, bodySize, &body.fields); err != nil {
+		return fail(err)
+	}
+
+	if body.r == nil {
+		body.done()
+		hr.Body = http.NoBody
+	} else {
+		hr.Body = body
+	}
+
+	return hr, nil
+}
+
+// CloseIdleConnections closes all connections currently kept alive.
+func (t *Transport) CloseIdleConnections() {
+	t.mu.Lock()
+	idle := t.idle
+	t.idle = nil
+	t.mu.Unlock()
+
+	for _, list := range idle {
+		for _, pc := range list {
+			pc.conn.Close()
+		}
+	}
+}
+
+func (t *Transport) getConn(ctx context.Context, u *url.URL, key string) (*persistConn, bool, error) {
+	t.mu.Lock()
+	if list := t.idle[key]; len(list) > 0 {
+		pc := list[len(list)-1]
+		t.idle[key] = list[:len(list)-1]
+		t.mu.Unlock()
+		return pc, true, nil
+	}
+	t.mu.Unlock()
+
+	dial := t.Dial
+	if dial == nil {
+		dial = (&net.Dialer{}).DialContext
+	}
+
+	addr := strings.TrimPrefix(key, u.Scheme+"://")
+	trace := contextTrace(ctx)
+
+	if trace != nil && trace.ConnectStart != nil {
+		trace.ConnectStart("tcp", addr)
+	}
+
+	conn, err := dial(ctx, "tcp", addr)
+
+	if trace != nil && trace.ConnectDone != nil {
+		trace.ConnectDone("tcp", addr, err)
+	}
+
+	if err != nil {
+		return nil, false, err
+	}
+
+	if u.Scheme == "https" {
+		var config *tls.Config
+
+		if t.TLSClientConfig != nil {
+			config = t.TLSClientConfig.Clone()
+		} else {
+			config = &tls.Config{}
+		}
+
+		if config.ServerName == "" {
+			config.ServerName = u.Hostname()
+		}
+
+		tc := tls.Client(conn, config)
+		if err := tc.HandshakeContext(ctx); err != nil {
+			conn.Close()
+			return nil, false, err
+		}
+
+		conn = tc
+	}
+
+	return &persistConn{
+		conn: conn,
+		r:    heat.NewBufioReader(bufio.NewReader(conn)),
+		w:    heat.NewBufioWriter(bufio.NewWriter(conn)),
+	}, false, nil
+}
+
+func (t *Transport) putConn(key string, pc *persistConn) {
+	max := t.MaxIdleConnsPerHost
+	if max <= 0 {
+		max = 2
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.idle == nil {
+		t.idle = make(map[string][]*persistConn)
+	}
+
+	if len(t.idle[key]) >= max {
+		pc.conn.Close()
+		return
+	}
+
+	t.idle[key] = append(t.idle[key], pc)
+}
+
+// The responseBody type releases the underlying connection once the body
+// has been read in full, and copies trailers into the http.Response.
+type responseBody struct {
+	r io.Reader
+
+	t       *Transport
+	pc      *persistConn
+	key     string
+	closing bool
+	stop    func() bool
+
+	fields  heat.Fields
+	trailer *http.Header
+
+	// Guards finished, and with it the trailers and the connection. Reads
+	// from r happen without holding it.
+	mu       sync.Mutex
+	finished bool
+}
+
+func (b *responseBody) Read(buf []byte) (int, error) {
+	b.mu.Lock()
+	finished := b.finished
+	b.mu.Unlock()
+
+	if finished {
+		return 0, io.EOF
+	}
+
+	// Don't hold the mutex while blocked on the network, so Close can
+	// interrupt the read by closing the connection.
+	n, err := b.r.Read(buf)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	// The body was closed while reading.
+	if b.finished {
+		return n, err
+	}
+
+	if err == io.EOF {
+		for _, f := range b.fields {
+			if *b.trailer == nil {
+				*b.trailer = make(http.Header)
+			}
+			b.trailer.Add(f.Name, f.Value)
+		}
+
+		b.done()
+	} else if err != nil {
+		b.finished = true
+		b.stop()
+		b.pc.conn.Close()
+	}
+
+	return n, err
+}
+
+func (b *responseBody) Close() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	// Closing a body before reaching its end makes the connection
+	// unusable.
+	if !b.finished {
+		b.finished = true
+		b.stop()
+		b.pc.conn.Close()
+	}
+
+	return nil
+}
+
+func (b *responseBody) done() {
+	b.finished = true
+
+	if b.stop() && !b.closing {
+		b.t.putConn(b.key, b.pc)
+	} else {
+		b.pc.conn.Close()
+	}
+}
+
+// The switchedBody type gives callers access to a connection which has
+// switched protocols, as net/http does for "101 Switching Protocols".
+type switchedBody struct {
+	pc *persistConn
+}
+
+func (b *switchedBody) Read(buf []byte) (int, error) {
+	return b.pc.r.Read(buf)
+}
+
+func (b *switchedBody) Write(buf []byte) (int, error) {
+	if _, err := b.pc.w.Write(buf); err != nil {
+		return 0, err
+	}
+	return len(buf), b.pc.w.Flush()
+}
+
+func (b *switchedBody) Close() error {
+	return b.pc.conn.Close()
+}
+
+// declaredTrailers returns a header with the names listed in a response's
+// "Trailer" field, to be filled in once the body has been read.
+func declaredTrailers(fields heat.Fields) http.Header {
+	var h http.Header
+
+	for _, f := range fields {
+		if !f.Is("Trailer") {
+			continue
+		}
+
+		for _, s := range strings.Split(f.Value, ",") {
+			if s = strings.TrimSpace(s); s != "" {
+				if h == nil {
+					h = make(http.Header)
+				}
+				h[http.CanonicalHeaderKey(s)] = nil
+			}
+		}
+	}
+
+	return h
+}
+
+// connKey identifies the connections a request can be sent over, in the
+// form "scheme://host:port".
+func connKey(u *url.URL) string {
+	port := u.Port()
+	if port == "" {
+		if u.Scheme == "https" {
+			port = "443"
+		} else {
+			port = "80"
+		}
+	}
+
+	return u.Scheme + "://" + net.JoinHostPort(u.Hostname(), port)
+}
+
+// replayable reports whether a request can safely be sent again after
+// failing on a reused connection.
+func replayable(req *heat.Request) bool {
+	if req.Body != nil {
+		return false
+	}
+
+	switch req.Method {
+	case "GET", "HEAD", "OPTIONS", "TRACE", "PUT", "DELETE":
+		return true
+	default:
+		return false
+	}
+}
+
+func closeBody(r *http.Request) {
+	if r.Body != nil {
+		r.Body.Close()
+	}
+}
@@ -0,0 +1,240 @@
+package nethttp
+
+import (
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/erkl/heat"
+)
+
+func TestTransport(t *testing.T) {
+	var conns int32
+
+	ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+
+		w.Header().Set("Trailer", "X-Checksum")
+		w.Header().Set("X-Body", string(body))
+		w.(http.Flusher).Flush()
+
+		io.WriteString(w, "response to "+r.URL.Path)
+		w.Header().Set("X-Checksum", "42")
+	}))
+
+	ts.Config.ConnState = func(c net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			atomic.AddInt32(&conns, 1)
+		}
+	}
+
+	ts.Start()
+	defer ts.Close()
+
+	client := &http.Client{Transport: &Transport{}}
+
+	for _, path := range []string{"/a", "/b", "/c"} {
+		resp, err := client.Post(ts.URL+path, "text/plain", strings.NewReader("data"))
+		if err != nil {
+			t.Fatalf("POST %s: %v", path, err)
+		}
+
+		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+
+		if err != nil || string(body) != "response to "+path {
+			t.Errorf("POST %s:", path)
+			t.Errorf("  got  %q, %v", body, err)
+			t.Errorf("  want %q, %v", "response to "+path, nil)
+		}
+
+		if v := resp.Header.Get("X-Body"); v != "data" {
+			t.Errorf("POST %s: got X-Body %q, want %q", path, v, "data")
+		}
+
+		if v := resp.Trailer.Get("X-Checksum"); v != "42" {
+			t.Errorf("POST %s: got trailer %q, want %q", path, v, "42")
+		}
+	}
+
+	if n := atomic.LoadInt32(&conns); n != 1 {
+		t.Errorf("got %d connections, want 1", n)
+	}
+}
+
+func TestTransportUnsizedBody(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		io.WriteString(w, "got:"+string(body))
+	}))
+	defer ts.Close()
+
+	client := &http.Client{Transport: &Transport{}}
+
+	// A body of unknown length must be sent rather than dropped.
+	req, _ := http.NewRequest("POST", ts.URL, io.MultiReader(strings.NewReader("hello")))
+	if req.ContentLength != 0 {
+		t.Fatalf("ContentLength: got %d, want 0", req.ContentLength)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("POST: %v", err)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+
+	if err != nil || string(body) != "got:hello" {
+		t.Errorf("POST with unsized body:")
+		t.Errorf("  got  %q, %v", body, err)
+		t.Errorf("  want %q, %v", "got:hello", nil)
+	}
+}
+
+func TestTransportCloseStalledBody(t *testing.T) {
+	release := make(chan struct{})
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", "10")
+		io.WriteString(w, "12345")
+		w.(http.Flusher).Flush()
+		<-release
+	}))
+	defer ts.Close()
+	defer close(release)
+
+	client := &http.Client{Transport: &Transport{}}
+
+	resp, err := client.Get(ts.URL)
+	if err != nil {
+		t.Fatalf("GET: %v", err)
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := io.ReadAll(resp.Body)
+		done <- err
+	}()
+
+	// Give the reader time to block on the stalled connection.
+	time.Sleep(50 * time.Millisecond)
+
+	closed := make(chan struct{})
+	go func() {
+		resp.Body.Close()
+		close(closed)
+	}()
+
+	select {
+	case <-closed:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Close blocked on a stalled read")
+	}
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Read wasn't interrupted by Close")
+	}
+}
+
+func TestTransportFieldOrder(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Skipf("listen: %v", err)
+	}
+	defer l.Close()
+
+	received := make(chan heat.Fields, 1)
+
+	go (&Server{
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			fields, _ := OriginalFields(r)
+			received <- fields
+		}),
+	}).Serve(l)
+
+	want := heat.Fields{
+		{Name: "Host", Value: l.Addr().String()},
+		{Name: "x-lower", Value: "1"},
+		{Name: "User-Agent", Value: "test"},
+		{Name: "X-UPPER", Value: "2"},
+	}
+
+	r, _ := http.NewRequest("GET", "http://"+l.Addr().String()+"/", nil)
+	r.Header.Set("User-Agent", "test")
+	r.Header.Set("X-Lower", "1")
+	r.Header.Set("X-Upper", "2")
+
+	resp, err := (&http.Client{Transport: &Transport{}}).Do(WithFields(r, want))
+	if err != nil {
+		t.Fatalf("GET: %v", err)
+	}
+	resp.Body.Close()
+
+	if got := <-received; !reflect.DeepEqual(got, want) {
+		t.Errorf("fields:")
+		t.Errorf("  got  %q", got)
+		t.Errorf("  want %q", want)
+	}
+}
+
+func TestTransportTrace(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "ok")
+	}))
+	defer ts.Close()
+
+	var events []string
+
+	trace := &heat.Trace{
+		ConnectStart: func(network, addr string) { events = append(events, "connect start") },
+		ConnectDone:  func(network, addr string, err error) { events = append(events, "connect done") },
+		GotConn: func(reused bool) {
+			if reused {
+				events = append(events, "reused conn")
+			} else {
+				events = append(events, "new conn")
+			}
+		},
+		FirstByte: func() { events = append(events, "first byte") },
+		ReadBody:  func(err error) { events = append(events, "read body") },
+	}
+
+	client := &http.Client{Transport: &Transport{}}
+
+	for i := 0; i < 2; i++ {
+		r, _ := http.NewRequest("GET", ts.URL, nil)
+
+		resp, err := client.Do(WithTrace(r, trace))
+		if err != nil {
+			t.Fatalf("GET: %v", err)
+		}
+		io.ReadAll(resp.Body)
+		resp.Body.Close()
+	}
+
+	want := []string{
+		"connect start",
+		"connect done",
+		"new conn",
+		"first byte",
+		"read body",
+		"reused conn",
+		"first byte",
+		"read body",
+	}
+
+	if !reflect.DeepEqual(events, want) {
+		t.Errorf("events:")
+		t.Errorf("  got  %q", events)
+		t.Errorf("  want %q", want)
+	}
+}
@@ -0,0 +1,465 @@
+package heat
+
+import (
+	"bytes"
+	"io"
+)
+
+// The EventType type enumerates the events emitted by a Parser.
+type EventType int
+
+const (
+	EventNone        EventType = iota // More input is needed.
+	EventRequestLine                  // Method, URI, Major and Minor are set.
+	EventStatusLine                   // Major, Minor, Status and Reason are set.
+	EventField                        // Name and Value are set.
+	EventHeaderEnd                    // Size holds the message's BodySize.
+	EventData                         // Data holds a piece of the message body.
+	EventChunk                        // Size holds the size of the next chunk.
+	EventTrailer                      // Name and Value are set.
+	EventMessageEnd                   // The message is complete.
+)
+
+// The Event struct represents a single parsing event. All byte slices
+// reference the data passed to Parser.Feed.
+type Event struct {
+	Type EventType
+
+	// Request-Line.
+	Method []byte
+	URI    []byte
+
+	// Request-Line and Status-Line.
+	Major int
+	Minor int
+
+	// Status-Line.
+	Status int
+	Reason []byte
+
+	// Header fields and trailers.
+	Name  []byte
+	Value []byte
+
+	// Body data.
+	Data []byte
+
+	// Body size (EventHeaderEnd) or chunk size (EventChunk).
+	Size int64
+}
+
+const (
+	defaultMaxLineSize   = 8 << 10
+	defaultMaxHeaderSize = 1 << 20
+)
+
+type parserState int
+
+const (
+	stateStartLine parserState = iota
+	stateHeader
+	stateBody
+	stateChunkSize
+	stateChunkData
+	stateChunkEnd
+	stateTrailer
+	stateMessageEnd
+	stateFailed
+)
+
+// The Parser type is a resumable HTTP/1.x message parser, for use with event
+// loops where blocking on an xo.Reader isn't an option. It accepts exactly
+// the same messages as ReadRequestHeader, ReadResponseHeader and OpenBody.
+//
+// Input is fed to the parser by the caller, which is responsible for
+// buffering any bytes the parser hasn't consumed yet. Because a line must be
+// complete before it can be parsed, the caller never has to buffer more than
+// MaxLineSize bytes.
+type Parser struct {
+	// Limits on the length of a single line (including any continuation
+	// lines of a header field), and on the total size of a message header.
+	// Zero values mean 8 KiB and 1 MiB, respectively.
+	MaxLineSize   int
+	MaxHeaderSize int
+
+	// Method of the request a response is being parsed for. Needed for
+	// determining the size of response bodies.
+	Method string
+
+	response bool
+	state    parserState
+	err      error
+
+	// Header state.
+	status  int
+	size    int
+	framing Fields
+
+	// Remaining bytes of the body or current chunk.
+	remaining int64
+}
+
+// NewRequestParser constructs a Parser for a stream of requests.
+func NewRequestParser() *Parser {
+	return &Parser{}
+}
+
+// NewResponseParser constructs a Parser for a stream of responses. The
+// method of each response's request must be assigned to the Method field
+// before the response is parsed.
+func NewResponseParser() *Parser {
+	return &Parser{response: true}
+}
+
+// Reset discards all parser state, preparing it for a new stream.
+func (p *Parser) Reset() {
+	p.state = stateStartLine
+	p.err = nil
+	p.status = 0
+	p.size = 0
+	p.framing = p.framing[:0]
+	p.remaining = 0
+}
+
+// Feed parses the next event from data, returning the number of bytes
+// consumed. Those bytes should be discarded by the caller before Feed is
+// called again, while any remaining bytes must be passed to the next call.
+// An EventNone event means more data is needed.
+//
+// Byte slices in the returned event reference data, and are only valid until
+// the caller discards the consumed bytes. Feed may rewrite header field
+// values in place while normalizing whitespace.
+func (p *Parser) Feed(data []byte) (int, Event, error) {
+	if p.err != nil {
+		return 0, Event{}, p.err
+	}
+
+	n, ev, err := p.feed(data)
+	if err != nil {
+		p.state, p.err = stateFailed, err
+	}
+
+	return n, ev, err
+}
+
+// Close signals the end of the input stream. Close returns nil if the stream
+// ended between messages, or in a body terminated by closing the connection,
+// and io.ErrUnexpectedEOF otherwise.
+func (p *Parser) Close() error {
+	if p.err != nil {
+		return p.err
+	}
+
+	switch {
+	case p.state == stateStartLine, p.state == stateMessageEnd:
+		return nil
+	case p.state == stateBody && p.remaining < 0:
+		return nil
+	default:
+		return io.ErrUnexpectedEOF
+	}
+}
+
+func (p *Parser) feed(data []byte) (int, Event, error) {
+	switch p.state {
+	case stateStartLine:
+		return p.startLine(data)
+	case stateHeader:
+		return p.header(data)
+	case stateBody:
+		return p.body(data)
+	case stateChunkSize:
+		return p.chunkSize(data)
+	case stateChunkData:
+		return p.body(data)
+	case stateChunkEnd:
+		return p.chunkEnd(data)
+	case stateTrailer:
+		return p.trailer(data)
+	case stateMessageEnd:
+		p.state = stateStartLine
+		return 0, Event{Type: EventMessageEnd}, nil
+	default:
+		return 0, Event{}, p.err
+	}
+}
+
+func (p *Parser) startLine(data []byte) (int, Event, error) {
+	line, err := p.line(data)
+	if line == nil {
+		return 0, Event{}, err
+	}
+
+	p.size = len(line)
+	p.framing = p.framing[:0]
+
+	if p.response {
+		major, minor, status, reason, err := parseStatusLine(line)
+		if err != nil {
+			return 0, Event{}, err
+		}
+
+		p.status = status
+		p.state = stateHeader
+
+		return len(line), Event{
+			Type:   EventStatusLine,
+			Major:  major,
+			Minor:  minor,
+			Status: status,
+			Reason: reason,
+		}, nil
+	} else {
+		method, uri, major, minor, err := parseRequestLine(line)
+		if err != nil {
+			return 0, Event{}, err
+		}
+
+		p.state = stateHeader
+
+		return len(line), Event{
+			Type:   EventRequestLine,
+			Method: method,
+			URI:    uri,
+			Major:  major,
+			Minor:  minor,
+		}, nil
+	}
+}
+
+func (p *Parser) header(data []byte) (int, Event, error) {
+	name, value, n, err := p.field(data, isBlankLine)
+	if n == 0 || err != nil {
+		if err == errMalformedHeader {
+			err = p.headerError()
+		}
+		return 0, Event{}, err
+	}
+
+	if p.size += n; p.size > p.maxHeaderSize() {
+		return 0, Event{}, ErrHeaderTooLarge
+	}
+
+	// Blank line.
+	if name == nil {
+		ev, err := p.headerEnd()
+		if err != nil {
+			return 0, Event{}, err
+		}
+		return n, ev, nil
+	}
+
+	// Remember the fields determining the size of the message body.
+	if s := bytestr(name); strcaseeq(s, "Content-Length") || strcaseeq(s, "Transfer-Encoding") {
+		p.framing = append(p.framing, Field{stringify(name), string(value)})
+	}
+
+	return n, Event{Type: EventField, Name: name, Value: value}, nil
+}
+
+func (p *Parser) headerEnd() (Event, error) {
+	var size BodySize
+	var err error
+
+	if p.response {
+		size, err = ResponseBodySize(&Response{Status: p.status, Fields: p.framing}, p.Method)
+	} else {
+		size, err = RequestBodySize(&Request{Fields: p.framing})
+	}
+
+	if err != nil {
+		return Event{}, err
+	}
+
+	switch {
+	case size == 0:
+		p.state = stateMessageEnd
+	case size > 0:
+		p.state, p.remaining = stateBody, int64(size)
+	case size == Chunked:
+		p.state = stateChunkSize
+	case size == Unbounded:
+		p.state, p.remaining = stateBody, -1
+	default:
+		return Event{}, ErrInvalidBodySize
+	}
+
+	return Event{Type: EventHeaderEnd, Size: int64(size)}, nil
+}
+
+func (p *Parser) body(data []byte) (int, Event, error) {
+	if len(data) == 0 {
+		return 0, Event{}, nil
+	}
+
+	// Bodies terminated by closing the connection are forwarded as-is.
+	if p.remaining < 0 {
+		return len(data), Event{Type: EventData, Data: data}, nil
+	}
+
+	if int64(len(data)) > p.remaining {
+		data = data[:p.remaining]
+	}
+
+	if p.remaining -= int64(len(data)); p.remaining == 0 {
+		if p.state == stateChunkData {
+			p.state = stateChunkEnd
+		} else {
+			p.state = stateMessageEnd
+		}
+	}
+
+	return len(data), Event{Type: EventData, Data: data}, nil
+}
+
+func (p *Parser) chunkSize(data []byte) (int, Event, error) {
+	line, err := p.line(data)
+	if line == nil {
+		return 0, Event{}, err
+	}
+
+	size, err := parseChunkSize(line)
+	if err != nil {
+		return 0, Event{}, err
+	}
+
+	if size == 0 {
+		p.state = stateTrailer
+	} else {
+		p.state, p.remaining = stateChunkData, size
+	}
+
+	return len(line), Event{Type: EventChunk, Size: size}, nil
+}
+
+func (p *Parser) chunkEnd(data []byte) (int, Event, error) {
+	line, err := p.line(data)
+	if line == nil {
+		return 0, Event{}, err
+	}
+
+	if !isChunkLineEnd(line) {
+		return 0, Event{}, ErrInvalidChunkedEncoding
+	}
+
+	// Parse the next chunk size line right away, so the empty line is
+	// only consumed together with it.
+	n, ev, err := p.chunkSize(data[len(line):])
+	if n == 0 {
+		return 0, Event{}, err
+	}
+
+	return len(line) + n, ev, nil
+}
+
+func (p *Parser) trailer(data []byte) (int, Event, error) {
+	name, value, n, err := p.field(data, isChunkLineEnd)
+	if n == 0 || err != nil {
+		if err == errMalformedHeader {
+			err = ErrInvalidChunkedEncoding
+		}
+		return 0, Event{}, err
+	}
+
+	// End of trailers.
+	if name == nil {
+		p.state = stateStartLine
+		return n, Event{Type: EventMessageEnd}, nil
+	}
+
+	return n, Event{Type: EventTrailer, Name: name, Value: value}, nil
+}
+
+// field parses a header field or trailer, including any continuation lines.
+// It returns a nil name if the next line ends the list according to end, and
+// zero bytes consumed if more data is needed.
+func (p *Parser) field(data []byte, end func([]byte) bool) (name, value []byte, n int, err error) {
+	line, err := p.line(data)
+	if line == nil {
+		return nil, nil, 0, err
+	}
+
+	if end(line) {
+		return nil, nil, len(line), nil
+	}
+
+	colon, err := fieldColon(line)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+
+	// Lines beginning with horizontal whitespace are continuations of the
+	// field value on the previous line, so we can't know whether the value
+	// is complete until we have seen the first byte of the following line.
+	for n = len(line); ; {
+		if n == len(data) {
+			if n >= p.maxLineSize() {
+				return nil, nil, 0, ErrHeaderTooLarge
+			}
+			return nil, nil, 0, nil
+		}
+
+		if c := data[n]; c != ' ' && c != '\t' {
+			break
+		}
+
+		next, err := p.line(data[n:])
+		if next == nil {
+			if err == nil && n >= p.maxLineSize() {
+				err = ErrHeaderTooLarge
+			}
+			return nil, nil, 0, err
+		}
+
+		n += len(next)
+	}
+
+	if n > p.maxLineSize() {
+		return nil, nil, 0, ErrHeaderTooLarge
+	}
+
+	name, value, err = splitField(data[:n], colon)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+
+	return name, value, n, nil
+}
+
+// line returns the first complete line in data, or nil if there is none.
+func (p *Parser) line(data []byte) ([]byte, error) {
+	if i := bytes.IndexByte(data, '\n'); i >= 0 {
+		if i >= p.maxLineSize() {
+			return nil, ErrHeaderTooLarge
+		}
+		return data[:i+1], nil
+	}
+
+	if len(data) >= p.maxLineSize() {
+		return nil, ErrHeaderTooLarge
+	}
+
+	return nil, nil
+}
+
+func (p *Parser) headerError() error {
+	if p.response {
+		return ErrResponseHeader
+	} else {
+		return ErrRequestHeader
+	}
+}
+
+func (p *Parser) maxLineSize() int {
+	if p.MaxLineSize > 0 {
+		return p.MaxLineSize
+	}
+	return defaultMaxLineSize
+}
+
+func (p *Parser) maxHeaderSize() int {
+	if p.MaxHeaderSize > 0 {
+		return p.MaxHeaderSize
+	}
+	return defaultMaxHeaderSize
+}
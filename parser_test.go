@@ -0,0 +1,192 @@
+package heat
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// feedParser feeds input to p in pieces of at most step bytes, the way an
+// event loop would, and returns a textual representation of all events.
+func feedParser(p *Parser, input string, step int) ([]string, error) {
+	var events []string
+	var buf []byte
+
+	for off := 0; ; {
+		n, ev, err := p.Feed(buf)
+		if err != nil {
+			return events, err
+		}
+
+		buf = buf[n:]
+
+		switch ev.Type {
+		case EventNone:
+			if off == len(input) {
+				return events, p.Close()
+			}
+			end := off + step
+			if end > len(input) {
+				end = len(input)
+			}
+			buf = append(buf, input[off:end]...)
+			off = end
+		case EventRequestLine:
+			events = append(events, fmt.Sprintf("request %s %s %d.%d", ev.Method, ev.URI, ev.Major, ev.Minor))
+		case EventStatusLine:
+			events = append(events, fmt.Sprintf("status %d.%d %d %s", ev.Major, ev.Minor, ev.Status, ev.Reason))
+		case EventField:
+			events = append(events, fmt.Sprintf("field %s: %s", ev.Name, ev.Value))
+		case EventHeaderEnd:
+			events = append(events, fmt.Sprintf("end %d", ev.Size))
+		case EventData:
+			// Merge consecutive data events, as their boundaries depend
+			// on how the input was split.
+			if last := len(events) - 1; last >= 0 && strings.HasPrefix(events[last], "data ") {
+				events[last] += string(ev.Data)
+			} else {
+				events = append(events, "data "+string(ev.Data))
+			}
+		case EventChunk:
+			events = append(events, fmt.Sprintf("chunk %d", ev.Size))
+		case EventTrailer:
+			events = append(events, fmt.Sprintf("trailer %s: %s", ev.Name, ev.Value))
+		case EventMessageEnd:
+			events = append(events, "done")
+		}
+	}
+}
+
+var parserTests = []struct {
+	response bool
+	method   string
+	in       string
+	out      []string
+	err      error
+}{
+	{
+		false, "",
+		"GET / HTTP/1.1\r\nHost: example.com\r\nX-Long: a\r\n b\r\n\r\n",
+		[]string{
+			"request GET / 1.1",
+			"field Host: example.com",
+			"field X-Long: a b",
+			"end 0",
+			"done",
+		},
+		nil,
+	},
+	{
+		false, "",
+		"POST /x HTTP/1.1\r\nContent-Length: 5\r\n\r\nhelloGET /y HTTP/1.0\n\n",
+		[]string{
+			"request POST /x 1.1",
+			"field Content-Length: 5",
+			"end 5",
+			"data hello",
+			"done",
+			"request GET /y 1.0",
+			"end 0",
+			"done",
+		},
+		nil,
+	},
+	{
+		false, "",
+		"POST / HTTP/1.1\r\nTransfer-Encoding: chunked\r\n\r\n3\r\nabc\r\n2;x=y\r\nde\r\n0\r\nX-Sum: 1\r\n\r\n",
+		[]string{
+			"request POST / 1.1",
+			"field Transfer-Encoding: chunked",
+			"end -1",
+			"chunk 3",
+			"data abc",
+			"chunk 2",
+			"data de",
+			"chunk 0",
+			"trailer X-Sum: 1",
+			"done",
+		},
+		nil,
+	},
+	{
+		true, "GET",
+		"HTTP/1.1 200 OK\r\nContent-Type: text/plain\r\n\r\nuntil close",
+		[]string{
+			"status 1.1 200 OK",
+			"field Content-Type: text/plain",
+			"end -3",
+			"data until close",
+		},
+		nil,
+	},
+	{
+		true, "HEAD",
+		"HTTP/1.1 200 OK\r\nContent-Length: 10\r\n\r\n",
+		[]string{
+			"status 1.1 200 OK",
+			"field Content-Length: 10",
+			"end 0",
+			"done",
+		},
+		nil,
+	},
+	{
+		false, "",
+		"GET / HTTP/1.1\r\n Host: example.com\r\n\r\n",
+		[]string{
+			"request GET / 1.1",
+		},
+		ErrRequestHeader,
+	},
+	{
+		false, "",
+		"GET / HTTP/1.1\r\nContent-Length: 1\r\nContent-Length: 2\r\n\r\n",
+		[]string{
+			"request GET / 1.1",
+			"field Content-Length: 1",
+			"field Content-Length: 2",
+		},
+		ErrInvalidContentLength,
+	},
+	{
+		false, "",
+		"GET / HTTP/1.1\r\nContent-Length: 10\r\n\r\nshort",
+		[]string{
+			"request GET / 1.1",
+			"field Content-Length: 10",
+			"end 10",
+			"data short",
+		},
+		io.ErrUnexpectedEOF,
+	},
+	{
+		false, "",
+		"GET / HTTP/1.1\r\nX-Big: " + strings.Repeat("x", 100) + "\r\n\r\n",
+		[]string{
+			"request GET / 1.1",
+		},
+		ErrHeaderTooLarge,
+	},
+}
+
+func TestParser(t *testing.T) {
+	for _, test := range parserTests {
+		for _, step := range []int{1, 7, len(test.in)} {
+			p := NewRequestParser()
+			if test.response {
+				p = NewResponseParser()
+				p.Method = test.method
+			}
+			p.MaxLineSize = 64
+
+			out, err := feedParser(p, test.in, step)
+			if err != test.err || !reflect.DeepEqual(out, test.out) {
+				t.Errorf("Parser.Feed(%q) in steps of %d:", test.in, step)
+				t.Errorf("  got  %q, %v", out, err)
+				t.Errorf("  want %q, %v", test.out, test.err)
+			}
+		}
+	}
+}
@@ -0,0 +1,281 @@
+package heat
+
+import (
+	"crypto/rand"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The ByteRange struct represents a single byte-range-spec from a "Range"
+// field. Open-ended ranges ("500-") have a Last value of -1, while suffix
+// ranges ("-500") have a First value of -1 and store the suffix length in
+// Last.
+type ByteRange struct {
+	First, Last int64
+}
+
+// The Span struct represents an absolute and satisfiable range of bytes
+// within a resource of known length.
+type Span struct {
+	Start, Length int64
+}
+
+// ParseRange parses the value of a "Range" field. Only the "bytes" range unit
+// is supported.
+func ParseRange(s string) ([]ByteRange, error) {
+	s = strtrim(s)
+
+	if len(s) < 6 || !strcaseeq(s[:6], "bytes=") {
+		return nil, ErrInvalidRange
+	}
+
+	var ranges []ByteRange
+
+	for _, spec := range strings.Split(s[6:], ",") {
+		if spec = strings.Trim(spec, " \t"); spec == "" {
+			// Empty list elements are allowed by RFC 7230 section 7.
+			continue
+		}
+
+		dash := strings.IndexByte(spec, '-')
+		if dash < 0 {
+			return nil, ErrInvalidRange
+		}
+
+		first, last := spec[:dash], spec[dash+1:]
+
+		var br ByteRange
+
+		if first == "" {
+			n, ok := atoi([]byte(last))
+			if !ok {
+				return nil, ErrInvalidRange
+			}
+			br = ByteRange{-1, n}
+		} else {
+			n, ok := atoi([]byte(first))
+			if !ok {
+				return nil, ErrInvalidRange
+			}
+			br = ByteRange{n, -1}
+
+			if last != "" {
+				m, ok := atoi([]byte(last))
+				if !ok || m < n {
+					return nil, ErrInvalidRange
+				}
+				br.Last = m
+			}
+		}
+
+		ranges = append(ranges, br)
+	}
+
+	if len(ranges) == 0 {
+		return nil, ErrInvalidRange
+	}
+
+	return ranges, nil
+}
+
+// ResolveRanges evaluates a list of byte ranges against a resource of the
+// specified size, dropping unsatisfiable ranges and coalescing overlapping or
+// adjacent ones. The returned spans are sorted by offset.
+//
+// If more than max ranges were requested (and max is positive), the function
+// returns ErrTooManyRanges; servers should then ignore the "Range" field and
+// serve the full representation. ErrRangeNotSatisfiable is returned when none
+// of the ranges overlap the resource.
+func ResolveRanges(ranges []ByteRange, size int64, max int) ([]Span, error) {
+	if max > 0 && len(ranges) > max {
+		return nil, ErrTooManyRanges
+	}
+
+	var spans []Span
+
+	for _, br := range ranges {
+		var start, end int64
+
+		switch {
+		case br.First < 0:
+			// Suffix range.
+			if br.Last == 0 || size == 0 {
+				continue
+			}
+			start, end = size-br.Last, size
+			if start < 0 {
+				start = 0
+			}
+
+		case br.First >= size:
+			continue
+
+		default:
+			start, end = br.First, size
+			if br.Last >= 0 && br.Last < size-1 {
+				end = br.Last + 1
+			}
+		}
+
+		// Guard against ranges which weren't produced by ParseRange.
+		if start < 0 || start >= end || end > size {
+			continue
+		}
+
+		spans = append(spans, Span{start, end - start})
+	}
+
+	if len(spans) == 0 {
+		return nil, ErrRangeNotSatisfiable
+	}
+
+	sort.Sort(spansByStart(spans))
+
+	// Coalesce overlapping and adjacent spans in place.
+	w := 0
+
+	for _, sp := range spans[1:] {
+		if prev := &spans[w]; sp.Start <= prev.Start+prev.Length {
+			if end := sp.Start + sp.Length; end > prev.Start+prev.Length {
+				prev.Length = end - prev.Start
+			}
+		} else {
+			w++
+			spans[w] = sp
+		}
+	}
+
+	return spans[:w+1], nil
+}
+
+type spansByStart []Span
+
+func (s spansByStart) Len() int           { return len(s) }
+func (s spansByStart) Less(i, j int) bool { return s[i].Start < s[j].Start }
+func (s spansByStart) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+
+// IfRange evaluates a request's "If-Range" field against the current
+// validators of a resource, returning true if the "Range" field should be
+// honored. Requests without an "If-Range" field always pass.
+func IfRange(req *Request, etag string, lastModified time.Time) bool {
+	v, ok := req.Fields.Get("If-Range")
+	if !ok {
+		return true
+	}
+
+	v = strtrim(v)
+
+	// Entity tags always start with a double quote or "W/", which can't be
+	// confused with the weekday of an HTTP-date.
+	if strings.HasPrefix(v, "\"") || strings.HasPrefix(v, "W/") {
+		return etagStrongMatch(v, etag)
+	}
+
+	if lastModified.IsZero() {
+		return false
+	}
+
+	t, ok := parseHTTPDate(v)
+	return ok && t.Equal(lastModified.Truncate(time.Second))
+}
+
+// NewPartialResponse constructs a "206 Partial Content" response serving the
+// specified spans of content, which must be size bytes long. A single span
+// is served with a "Content-Range" field, while multiple spans produce a
+// "multipart/byteranges" body. The contentType argument is used as the
+// "Content-Type" of the response or each individual part, and may be empty.
+//
+// An empty list of spans produces a "416 Range Not Satisfiable" response.
+func NewPartialResponse(content io.ReaderAt, size int64, spans []Span, contentType string) *Response {
+	if len(spans) == 0 {
+		return NewRangeNotSatisfiableResponse(size)
+	}
+
+	resp := NewResponse(206, ReasonPhrase(206))
+
+	if len(spans) == 1 {
+		sp := spans[0]
+
+		resp.Fields = Fields{
+			{"Content-Range", contentRange(sp, size)},
+			{"Content-Length", strconv.FormatInt(sp.Length, 10)},
+		}
+
+		if contentType != "" {
+			resp.Fields.Add("Content-Type", contentType)
+		}
+
+		resp.Body = io.NopCloser(io.NewSectionReader(content, sp.Start, sp.Length))
+		return resp
+	}
+
+	boundary := randomBoundary()
+
+	var parts []io.Reader
+	var length int64
+
+	for i, sp := range spans {
+		var head string
+
+		if i > 0 {
+			head = "\r\n"
+		}
+
+		head += "--" + boundary + "\r\n"
+		if contentType != "" {
+			head += "Content-Type: " + contentType + "\r\n"
+		}
+		head += "Content-Range: " + contentRange(sp, size) + "\r\n\r\n"
+
+		parts = append(parts, strings.NewReader(head), io.NewSectionReader(content, sp.Start, sp.Length))
+		length += int64(len(head)) + sp.Length
+	}
+
+	tail := "\r\n--" + boundary + "--\r\n"
+	parts = append(parts, strings.NewReader(tail))
+	length += int64(len(tail))
+
+	resp.Fields = Fields{
+		{"Content-Type", "multipart/byteranges; boundary=" + boundary},
+		{"Content-Length", strconv.FormatInt(length, 10)},
+	}
+
+	resp.Body = io.NopCloser(io.MultiReader(parts...))
+	return resp
+}
+
+// NewRangeNotSatisfiableResponse constructs a "416 Range Not Satisfiable"
+// response for a resource of the specified size.
+func NewRangeNotSatisfiableResponse(size int64) *Response {
+	resp := NewResponse(416, ReasonPhrase(416))
+	resp.Fields = Fields{
+		{"Content-Range", "bytes */" + strconv.FormatInt(size, 10)},
+		{"Content-Length", "0"},
+	}
+	return resp
+}
+
+func contentRange(sp Span, size int64) string {
+	buf := make([]byte, 6+20+1+20+1+20)
+
+	n := copy(buf[0:], "bytes ")
+	n += itoa(buf[n:], sp.Start)
+	n += copy(buf[n:], "-")
+	n += itoa(buf[n:], sp.Start+sp.Length-1)
+	n += copy(buf[n:], "/")
+	n += itoa(buf[n:], size)
+
+	return string(buf[:n])
+}
+
+func randomBoundary() string {
+	var b [16]byte
+	if _, err := io.ReadFull(rand.Reader, b[:]); err != nil {
+		panic(err)
+	}
+
+	return hexString(b[:])
+}
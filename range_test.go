@@ -0,0 +1,201 @@
+package heat
+
+import (
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+var parseRangeTests = []struct {
+	in  string
+	out []ByteRange
+	err error
+}{
+	{"bytes=0-499", []ByteRange{{0, 499}}, nil},
+	{"bytes=500-", []ByteRange{{500, -1}}, nil},
+	{"bytes=-500", []ByteRange{{-1, 500}}, nil},
+	{"Bytes=0-0, ,-1,9-", []ByteRange{{0, 0}, {-1, 1}, {9, -1}}, nil},
+	{"", nil, ErrInvalidRange},
+	{"items=0-1", nil, ErrInvalidRange},
+	{"bytes=", nil, ErrInvalidRange},
+	{"bytes=5-4", nil, ErrInvalidRange},
+	{"bytes=-", nil, ErrInvalidRange},
+	{"bytes=1", nil, ErrInvalidRange},
+	{"bytes=18446744073709551615-", nil, ErrInvalidRange},
+	{"bytes=0-18446744073709551616", nil, ErrInvalidRange},
+	{"bytes=-9223372036854775808", nil, ErrInvalidRange},
+}
+
+func TestParseRange(t *testing.T) {
+	for _, test := range parseRangeTests {
+		out, err := ParseRange(test.in)
+		if !reflect.DeepEqual(out, test.out) || err != test.err {
+			t.Errorf("ParseRange(%q):", test.in)
+			t.Errorf("  got  %v, %v", out, err)
+			t.Errorf("  want %v, %v", test.out, test.err)
+		}
+	}
+}
+
+var resolveRangesTests = []struct {
+	in   []ByteRange
+	size int64
+	max  int
+	out  []Span
+	err  error
+}{
+	{[]ByteRange{{0, 499}}, 1000, 0, []Span{{0, 500}}, nil},
+	{[]ByteRange{{500, 5000}}, 1000, 0, []Span{{500, 500}}, nil},
+	{[]ByteRange{{-1, 200}}, 1000, 0, []Span{{800, 200}}, nil},
+	{[]ByteRange{{-1, 2000}}, 1000, 0, []Span{{0, 1000}}, nil},
+	{[]ByteRange{{500, 599}, {0, 99}, {100, 199}, {550, -1}}, 1000, 0, []Span{{0, 200}, {500, 500}}, nil},
+	{[]ByteRange{{1000, -1}, {-1, 0}}, 1000, 0, nil, ErrRangeNotSatisfiable},
+	{[]ByteRange{{0, 1}, {2, 3}, {4, 5}}, 1000, 2, nil, ErrTooManyRanges},
+	{[]ByteRange{{-1, -1}, {5, 4}}, 100, 10, nil, ErrRangeNotSatisfiable},
+}
+
+func TestResolveRanges(t *testing.T) {
+	for _, test := range resolveRangesTests {
+		out, err := ResolveRanges(test.in, test.size, test.max)
+		if !reflect.DeepEqual(out, test.out) || err != test.err {
+			t.Errorf("ResolveRanges(%v, %d, %d):", test.in, test.size, test.max)
+			t.Errorf("  got  %v, %v", out, err)
+			t.Errorf("  want %v, %v", test.out, test.err)
+		}
+	}
+}
+
+var ifRangeTests = []struct {
+	in   string
+	etag string
+	mod  time.Time
+	out  bool
+}{
+	{"", `"a"`, time.Time{}, true},
+	{`"a"`, `"a"`, time.Time{}, true},
+	{` "a" `, `"a"`, time.Time{}, true},
+	{`"a"`, `"b"`, time.Time{}, false},
+	{`W/"a"`, `W/"a"`, time.Time{}, false},
+	{`"a"`, `W/"a"`, time.Time{}, false},
+	{"Sun, 06 Nov 1994 08:49:37 GMT", "", time.Date(1994, 11, 6, 8, 49, 37, 500, time.UTC), true},
+	{"Sun, 06 Nov 1994 08:49:37 GMT", "", time.Date(1994, 11, 6, 8, 49, 38, 0, time.UTC), false},
+	{"Sun, 06 Nov 1994 08:49:37 GMT", `"a"`, time.Time{}, false},
+	{"yesterday", "", time.Date(1994, 11, 6, 8, 49, 37, 0, time.UTC), false},
+}
+
+func TestIfRange(t *testing.T) {
+	for _, test := range ifRangeTests {
+		req := &Request{Method: "GET", URI: "/"}
+		if test.in != "" {
+			req.Fields.Add("If-Range", test.in)
+		}
+
+		if out := IfRange(req, test.etag, test.mod); out != test.out {
+			t.Errorf("IfRange(%q, %q, %v):", test.in, test.etag, test.mod)
+			t.Errorf("  got  %v", out)
+			t.Errorf("  want %v", test.out)
+		}
+	}
+}
+
+var newPartialResponseTests = []struct {
+	spans       []Span
+	contentType string
+	fields      Fields
+	body        string
+}{
+	{
+		[]Span{{2, 3}},
+		"",
+		Fields{{"Content-Range", "bytes 2-4/10"}, {"Content-Length", "3"}},
+		"234",
+	},
+	{
+		[]Span{{0, 10}},
+		"text/plain",
+		Fields{{"Content-Range", "bytes 0-9/10"}, {"Content-Length", "10"}, {"Content-Type", "text/plain"}},
+		"0123456789",
+	},
+	{
+		[]Span{{0, 2}, {8, 2}},
+		"",
+		Fields{{"Content-Type", "multipart/byteranges; boundary=BOUNDARY"}},
+		"--BOUNDARY\r\nContent-Range: bytes 0-1/10\r\n\r\n01" +
+			"\r\n--BOUNDARY\r\nContent-Range: bytes 8-9/10\r\n\r\n89" +
+			"\r\n--BOUNDARY--\r\n",
+	},
+	{
+		[]Span{{1, 1}, {3, 1}, {5, 5}},
+		"text/plain",
+		Fields{{"Content-Type", "multipart/byteranges; boundary=BOUNDARY"}},
+		"--BOUNDARY\r\nContent-Type: text/plain\r\nContent-Range: bytes 1-1/10\r\n\r\n1" +
+			"\r\n--BOUNDARY\r\nContent-Type: text/plain\r\nContent-Range: bytes 3-3/10\r\n\r\n3" +
+			"\r\n--BOUNDARY\r\nContent-Type: text/plain\r\nContent-Range: bytes 5-9/10\r\n\r\n56789" +
+			"\r\n--BOUNDARY--\r\n",
+	},
+}
+
+func TestNewPartialResponse(t *testing.T) {
+	const content = "0123456789"
+
+	for _, test := range newPartialResponseTests {
+		resp := NewPartialResponse(strings.NewReader(content), int64(len(content)), test.spans, test.contentType)
+
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			t.Errorf("NewPartialResponse(%v): reading body: %v", test.spans, err)
+			continue
+		}
+
+		fields := append(Fields(nil), resp.Fields...)
+
+		// Check the Content-Length arithmetic against the actual body.
+		if cl, _ := fields.Get("Content-Length"); cl != strconv.Itoa(len(body)) {
+			t.Errorf("NewPartialResponse(%v): Content-Length %s, body length %d", test.spans, cl, len(body))
+		}
+
+		// Substitute the random boundary, which also makes the length of
+		// multipart bodies unpredictable.
+		if ct, _ := fields.Get("Content-Type"); strings.HasPrefix(ct, "multipart/") {
+			boundary := ct[strings.Index(ct, "boundary=")+9:]
+			fields.Set("Content-Type", strings.Replace(ct, boundary, "BOUNDARY", 1))
+			fields.Remove("Content-Length")
+			body = []byte(strings.ReplaceAll(string(body), boundary, "BOUNDARY"))
+		}
+
+		if resp.Status != 206 || !reflect.DeepEqual(fields, test.fields) || string(body) != test.body {
+			t.Errorf("NewPartialResponse(%v, %q):", test.spans, test.contentType)
+			t.Errorf("  got  %d %q %q", resp.Status, fields, body)
+			t.Errorf("  want %d %q %q", 206, test.fields, test.body)
+		}
+	}
+
+	// Without any spans there's nothing to serve.
+	if resp := NewPartialResponse(strings.NewReader(content), int64(len(content)), nil, ""); resp.Status != 416 || resp.Body != nil {
+		t.Errorf("NewPartialResponse(nil):")
+		t.Errorf("  got  %d %q", resp.Status, resp.Fields)
+		t.Errorf("  want %d", 416)
+	}
+}
+
+func TestNewRangeNotSatisfiableResponse(t *testing.T) {
+	var tests = []struct {
+		size   int64
+		fields Fields
+	}{
+		{0, Fields{{"Content-Range", "bytes */0"}, {"Content-Length", "0"}}},
+		{1234, Fields{{"Content-Range", "bytes */1234"}, {"Content-Length", "0"}}},
+	}
+
+	for _, test := range tests {
+		resp := NewRangeNotSatisfiableResponse(test.size)
+		if resp.Status != 416 || resp.Reason != ReasonPhrase(416) || resp.Body != nil || !reflect.DeepEqual(resp.Fields, test.fields) {
+			t.Errorf("NewRangeNotSatisfiableResponse(%d):", test.size)
+			t.Errorf("  got  %d %q %q", resp.Status, resp.Reason, resp.Fields)
+			t.Errorf("  want %d %q %q", 416, ReasonPhrase(416), test.fields)
+		}
+	}
+}
@@ -0,0 +1,263 @@
+// Package record captures HTTP exchanges exactly as they crossed the wire,
+// and replays them later.
+//
+// Recording happens on the server side of a connection: bytes consumed from
+// a connection's xo.Reader (by e.g. heat.ReadRequestHeader and heat.OpenBody)
+// make up the request, and bytes written to its xo.Writer (by e.g.
+// heat.WriteResponseHeader and heat.WriteBody) make up the response.
+//
+// Recordings are stored as a stream of JSON objects, one per line and
+// exchange.
+package record
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/erkl/xo"
+)
+
+// The Exchange struct represents a single recorded request and response.
+type Exchange struct {
+	// Identifies the connection the exchange took place on, unique within
+	// a single Recorder. Exchanges from the same connection are recorded
+	// in order.
+	Conn uint64 `json:"conn"`
+
+	// Time of the first request byte being read, and of the exchange
+	// being ended.
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+
+	// Raw request and response, including bodies.
+	Request  []byte `json:"request"`
+	Response []byte `json:"response"`
+}
+
+// The Recorder type writes exchanges recorded on any number of connections
+// to a single io.Writer. It is safe for concurrent use.
+type Recorder struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+
+	conns uint64
+}
+
+// NewRecorder constructs a Recorder writing to w.
+func NewRecorder(w io.Writer) *Recorder {
+	return &Recorder{enc: json.NewEncoder(w)}
+}
+
+// Conn starts recording a connection. The returned Conn's Reader and Writer
+// fields must be used in place of r and w.
+func (rec *Recorder) Conn(r xo.Reader, w xo.Writer) *Conn {
+	c := &Conn{
+		rec: rec,
+		id:  atomic.AddUint64(&rec.conns, 1),
+	}
+
+	c.Reader = &reader{Reader: r, c: c}
+	c.Writer = &writer{Writer: w, c: c}
+
+	return c
+}
+
+func (rec *Recorder) write(ex *Exchange) error {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+
+	return rec.enc.Encode(ex)
+}
+
+// The Conn type records the exchanges of a single connection.
+type Conn struct {
+	Reader xo.Reader
+	Writer xo.Writer
+
+	rec *Recorder
+	id  uint64
+
+	mu       sync.Mutex
+	start    time.Time
+	request  []byte
+	response []byte
+}
+
+// End marks the end of an exchange, writing everything read and written
+// since the previous call to the recording. Calling End when nothing has
+// been read or written does nothing.
+func (c *Conn) End() error {
+	c.mu.Lock()
+
+	if len(c.request) == 0 && len(c.response) == 0 {
+		c.mu.Unlock()
+		return nil
+	}
+
+	ex := &Exchange{
+		Conn:     c.id,
+		Start:    c.start,
+		End:      time.Now(),
+		Request:  c.request,
+		Response: c.response,
+	}
+
+	if ex.Start.IsZero() {
+		ex.Start = ex.End
+	}
+
+	c.start = time.Time{}
+	c.request = nil
+	c.response = nil
+
+	c.mu.Unlock()
+
+	return c.rec.write(ex)
+}
+
+func (c *Conn) read(buf []byte) {
+	if len(buf) == 0 {
+		return
+	}
+
+	c.mu.Lock()
+	if len(c.request) == 0 && c.start.IsZero() {
+		c.start = time.Now()
+	}
+	c.request = append(c.request, buf...)
+	c.mu.Unlock()
+}
+
+func (c *Conn) wrote(buf []byte) {
+	c.mu.Lock()
+	c.response = append(c.response, buf...)
+	c.mu.Unlock()
+}
+
+// The reader type records bytes as they are consumed. Because heat parses
+// header fields in place, peeked bytes are copied right away, but only
+// recorded once consumed.
+type reader struct {
+	xo.Reader
+	c *Conn
+
+	pending []byte
+}
+
+func (r *reader) Peek(n int) ([]byte, error) {
+	buf, err := r.Reader.Peek(n)
+	if len(buf) > len(r.pending) {
+		r.pending = append(r.pending, buf[len(r.pending):]...)
+	}
+	return buf, err
+}
+
+func (r *reader) Read(buf []byte) (int, error) {
+	n, err := r.Reader.Read(buf)
+	if n > 0 {
+		r.take(n, buf)
+	}
+	return n, err
+}
+
+func (r *reader) Consume(n int) error {
+	if n > len(r.pending) {
+		if _, err := r.Peek(n); err != nil {
+			return err
+		}
+	}
+
+	r.take(n, nil)
+	return r.Reader.Consume(n)
+}
+
+// take records n bytes leaving the reader, preferring pristine copies of
+// peeked data over buf.
+func (r *reader) take(n int, buf []byte) {
+	m := n
+	if m > len(r.pending) {
+		m = len(r.pending)
+	}
+
+	r.c.read(r.pending[:m])
+
+	if r.pending = r.pending[m:]; len(r.pending) == 0 {
+		r.pending = nil
+	}
+
+	if n > m {
+		r.c.read(buf[m:n])
+	}
+}
+
+type writer struct {
+	xo.Writer
+	c *Conn
+
+	res []byte
+}
+
+func (w *writer) Write(buf []byte) (int, error) {
+	n, err := w.Writer.Write(buf)
+	if n > 0 {
+		w.c.wrote(buf[:n])
+	}
+	return n, err
+}
+
+func (w *writer) Reserve(n int) ([]byte, error) {
+	buf, err := w.Writer.Reserve(n)
+	w.res = buf
+	return buf, err
+}
+
+func (w *writer) Commit(n int) error {
+	// Record the reserved bytes before committing them, as the underlying
+	// writer may reuse its buffer as soon as they've been sent.
+	if n <= len(w.res) {
+		w.c.wrote(w.res[:n])
+	}
+
+	w.res = nil
+	return w.Writer.Commit(n)
+}
+
+// The Reader type reads exchanges from a recording.
+type Reader struct {
+	dec *json.Decoder
+}
+
+// NewReader constructs a Reader reading a recording from r.
+func NewReader(r io.Reader) *Reader {
+	return &Reader{dec: json.NewDecoder(r)}
+}
+
+// Next returns the next exchange in the recording, or io.EOF once there are
+// no more.
+func (r *Reader) Next() (*Exchange, error) {
+	ex := new(Exchange)
+	if err := r.dec.Decode(ex); err != nil {
+		return nil, err
+	}
+	return ex, nil
+}
+
+// ReadAll reads all exchanges from a recording.
+func ReadAll(r io.Reader) ([]*Exchange, error) {
+	var list []*Exchange
+
+	rr := NewReader(r)
+	for {
+		ex, err := rr.Next()
+		if err == io.EOF {
+			return list, nil
+		} else if err != nil {
+			return nil, err
+		}
+
+		list = append(list, ex)
+	}
+}
@@ -0,0 +1,103 @@
+package record
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/erkl/heat"
+)
+
+const (
+	rawRequest  = "POST /echo HTTP/1.1\r\nHost: example.com\r\nX-Spaced:   a  \r\n  b\r\nContent-Length: 4\r\n\r\nping"
+	rawResponse = "HTTP/1.1 200 OK\r\nContent-Length: 4\r\n\r\npong"
+)
+
+func TestRecorder(t *testing.T) {
+	var out, rec bytes.Buffer
+
+	recorder := NewRecorder(&rec)
+
+	in := heat.NewBufioReader(bufio.NewReader(strings.NewReader(rawRequest + rawRequest)))
+	c := recorder.Conn(in, heat.NewBufioWriter(bufio.NewWriter(&out)))
+
+	for i := 0; i < 2; i++ {
+		req, err := heat.ReadRequestHeader(c.Reader)
+		if err != nil {
+			t.Fatalf("ReadRequestHeader: %v", err)
+		}
+
+		size, _ := heat.RequestBodySize(req)
+		body, _ := heat.OpenBody(c.Reader, size)
+
+		resp := heat.NewResponse(200, "OK")
+		resp.Fields.Add("Content-Length", "4")
+
+		if err := heat.WriteResponseHeader(c.Writer, resp); err != nil {
+			t.Fatalf("WriteResponseHeader: %v", err)
+		}
+		if err := heat.WriteBody(c.Writer, strings.NewReader("pong"), 4); err != nil {
+			t.Fatalf("WriteBody: %v", err)
+		}
+
+		// Drain the request body only after responding, which must not
+		// affect what is recorded.
+		if _, err := io.ReadAll(body); err != nil {
+			t.Fatalf("reading body: %v", err)
+		}
+
+		if err := c.End(); err != nil {
+			t.Fatalf("End: %v", err)
+		}
+	}
+
+	exchanges, err := ReadAll(&rec)
+	if err != nil {
+		t.Fatalf("ReadAll: %v", err)
+	}
+
+	if len(exchanges) != 2 {
+		t.Fatalf("got %d exchanges, want 2", len(exchanges))
+	}
+
+	for _, ex := range exchanges {
+		if string(ex.Request) != rawRequest || string(ex.Response) != rawResponse || ex.Conn != 1 {
+			t.Errorf("recorded exchange:")
+			t.Errorf("  got  %d %q %q", ex.Conn, ex.Request, ex.Response)
+			t.Errorf("  want %d %q %q", 1, rawRequest, rawResponse)
+		}
+	}
+}
+
+func TestReplay(t *testing.T) {
+	exchanges := []*Exchange{
+		{Conn: 1, Request: []byte(rawRequest), Response: []byte(rawResponse)},
+		{Conn: 1, Request: []byte("GET /missing HTTP/1.1\r\nHost: example.com\r\n\r\n"), Response: nil},
+	}
+
+	s, err := NewServer(exchanges[:1])
+	if err != nil {
+		t.Fatalf("NewServer: %v", err)
+	}
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Skipf("listen: %v", err)
+	}
+	defer l.Close()
+
+	go s.Serve(l)
+
+	results := Replay(l.Addr().String(), exchanges)
+
+	if r := results[0]; r.Err != nil || r.Response.Status != 200 || string(r.Body) != "pong" {
+		t.Errorf("replaying %q: got %+v, %q, %v", rawRequest, r.Response, r.Body, r.Err)
+	}
+
+	if r := results[1]; r.Err != nil || r.Response.Status != 404 {
+		t.Errorf("replaying unrecorded request: got %+v, %v", r.Response, r.Err)
+	}
+}
@@ -0,0 +1,288 @@
+package record
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+
+	"github.com/erkl/heat"
+	"github.com/erkl/xo"
+)
+
+// The Server type serves recorded responses to incoming requests matching
+// recorded ones.
+//
+// A request matches a recorded request with the same method, Request-URI
+// and body. Each recorded exchange is served once, in recording order, after
+// which the last matching exchange is served repeatedly. Requests without a
+// match get a "404 Not Found" response.
+type Server struct {
+	mu      sync.Mutex
+	entries []*entry
+}
+
+type entry struct {
+	ex     *Exchange
+	method string
+	uri    string
+	body   []byte
+	served bool
+}
+
+// NewServer constructs a Server for a list of exchanges.
+func NewServer(exchanges []*Exchange) (*Server, error) {
+	s := new(Server)
+
+	for _, ex := range exchanges {
+		req, body, err := parseRequest(ex.Request)
+		if err != nil {
+			return nil, err
+		}
+
+		s.entries = append(s.entries, &entry{
+			ex:     ex,
+			method: req.Method,
+			uri:    req.URI,
+			body:   body,
+		})
+	}
+
+	return s, nil
+}
+
+// Serve accepts connections from l, serving each one in a new goroutine.
+func (s *Server) Serve(l net.Listener) error {
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			return err
+		}
+
+		go s.ServeConn(conn)
+	}
+}
+
+// ServeConn serves requests from a single connection until either side
+// closes it.
+func (s *Server) ServeConn(conn net.Conn) error {
+	defer conn.Close()
+
+	r := heat.NewBufioReader(bufio.NewReader(conn))
+	w := heat.NewBufioWriter(bufio.NewWriter(conn))
+
+	for {
+		req, body, err := readRequest(r)
+		if err != nil {
+			if err == io.EOF {
+				err = nil
+			}
+			return err
+		}
+
+		if ex := s.match(req.Method, req.URI, body); ex != nil {
+			_, err = w.Write(ex.Response)
+		} else {
+			err = writeNotFound(w)
+		}
+
+		if err != nil {
+			return err
+		}
+
+		if err := w.Flush(); err != nil {
+			return err
+		}
+
+		if heat.Closing(req.Major, req.Minor, req.Fields) {
+			return nil
+		}
+	}
+}
+
+func (s *Server) match(method, uri string, body []byte) *Exchange {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var last *entry
+
+	for _, e := range s.entries {
+		if e.method != method || e.uri != uri || !bytes.Equal(e.body, body) {
+			continue
+		}
+
+		if !e.served {
+			e.served = true
+			return e.ex
+		}
+
+		last = e
+	}
+
+	if last != nil {
+		return last.ex
+	}
+
+	return nil
+}
+
+func writeNotFound(w xo.Writer) error {
+	const msg = "no matching exchange recorded\n"
+
+	resp := heat.NewResponse(404, heat.ReasonPhrase(404))
+	resp.Fields.Add("Content-Type", "text/plain; charset=utf-8")
+	resp.Fields.Add("Content-Length", strconv.Itoa(len(msg)))
+
+	if err := heat.WriteResponseHeader(w, resp); err != nil {
+		return err
+	}
+
+	_, err := io.WriteString(w, msg)
+	return err
+}
+
+// The Result struct holds the outcome of replaying a single exchange.
+type Result struct {
+	Exchange *Exchange
+
+	// The response received, and its body.
+	Response *heat.Response
+	Body     []byte
+
+	Err error
+}
+
+// Replay sends the recorded requests to the server at addr, and reads the
+// responses. Exchanges recorded on the same connection are replayed in order
+// over a single new connection. Results are returned in the same order as
+// exchanges.
+func Replay(addr string, exchanges []*Exchange) []Result {
+	results := make([]Result, len(exchanges))
+
+	// Group exchanges by connection, in order of first appearance.
+	var order []uint64
+	var groups = make(map[uint64][]int)
+
+	for i, ex := range exchanges {
+		if _, ok := groups[ex.Conn]; !ok {
+			order = append(order, ex.Conn)
+		}
+		groups[ex.Conn] = append(groups[ex.Conn], i)
+	}
+
+	for _, id := range order {
+		replayConn(addr, exchanges, groups[id], results)
+	}
+
+	return results
+}
+
+func replayConn(addr string, exchanges []*Exchange, indices []int, results []Result) {
+	var conn net.Conn
+	var r xo.Reader
+	var w xo.Writer
+
+	defer func() {
+		if conn != nil {
+			conn.Close()
+		}
+	}()
+
+	for _, i := range indices {
+		ex := exchanges[i]
+		res := &results[i]
+		res.Exchange = ex
+
+		req, _, err := parseRequest(ex.Request)
+		if err != nil {
+			res.Err = err
+			continue
+		}
+
+		if conn == nil {
+			if conn, err = net.Dial("tcp", addr); err != nil {
+				res.Err = err
+				continue
+			}
+
+			r = heat.NewBufioReader(bufio.NewReader(conn))
+			w = heat.NewBufioWriter(bufio.NewWriter(conn))
+		}
+
+		res.Response, res.Body, res.Err = roundTrip(r, w, req.Method, ex.Request)
+
+		// Start over with a new connection after errors, or when either side
+		// asked for the connection to be closed.
+		if res.Err != nil || heat.Closing(req.Major, req.Minor, req.Fields) ||
+			heat.Closing(res.Response.Major, res.Response.Minor, res.Response.Fields) {
+			conn.Close()
+			conn = nil
+		}
+	}
+}
+
+func roundTrip(r xo.Reader, w xo.Writer, method string, raw []byte) (*heat.Response, []byte, error) {
+	if _, err := w.Write(raw); err != nil {
+		return nil, nil, err
+	}
+
+	if err := w.Flush(); err != nil {
+		return nil, nil, err
+	}
+
+	// Skip interim responses.
+	for {
+		resp, err := heat.ReadResponseHeader(r)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if 100 <= resp.Status && resp.Status <= 199 && resp.Status != 101 {
+			continue
+		}
+
+		size, err := heat.ResponseBodySize(resp, method)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		body, err := heat.OpenBody(r, size)
+		if err != nil || body == nil {
+			return resp, nil, err
+		}
+
+		data, err := io.ReadAll(body)
+		return resp, data, err
+	}
+}
+
+// parseRequest parses a raw, recorded request.
+func parseRequest(raw []byte) (*heat.Request, []byte, error) {
+	return readRequest(heat.NewBufioReader(bufio.NewReader(bytes.NewReader(raw))))
+}
+
+func readRequest(r xo.Reader) (*heat.Request, []byte, error) {
+	req, err := heat.ReadRequestHeader(r)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	size, err := heat.RequestBodySize(req)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	body, err := heat.OpenBody(r, size)
+	if err != nil || body == nil {
+		return req, nil, err
+	}
+
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return req, data, nil
+}
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"io"
 	"net/url"
+	"strings"
 
 	"github.com/erkl/xo"
 )
@@ -29,6 +30,9 @@ type Request struct {
 	// incoming requests and destination for outgoing requests).
 	Scheme string
 	Remote string
+
+	// Backing storage for strings, used by ReadRequestHeaderInto.
+	arena arena
 }
 
 // NewRequest constructs a minimal Request instance given a method and
@@ -58,27 +62,125 @@ func (r *Request) ParseQuery() (url.Values, error) {
 	return u.Query(), nil
 }
 
-// ResolveURL attempts to combine the request's Scheme property, its "Host"
-// header field and the Request-URI to an absolute URL.
+// ResolveURL reconstructs the request's effective request URI, as described
+// in RFC 9112 section 3.3. Absolute-form targets carry their own scheme and
+// authority. For other forms the scheme is taken from the request's Scheme
+// property (defaulting to "http"), and the authority from its "Host" header
+// field.
+//
+// An error is returned when the "Host" field is missing (except for
+// absolute-form targets), appears more than once, or doesn't agree with the
+// authority of an absolute-form or authority-form target.
 func (r *Request) ResolveURL() (*url.URL, error) {
-	host, ok := r.Fields.Get("Host")
-	if !ok {
-		return nil, ErrRequestNoHost
+	t, err := r.Target()
+	if err != nil {
+		return nil, err
 	}
 
-	u, err := url.ParseRequestURI(r.URI)
+	host, err := r.host()
 	if err != nil {
 		return nil, err
 	}
 
-	u.Scheme = r.Scheme
-	u.Host = host
+	u := &url.URL{
+		Scheme: r.Scheme,
+		Host:   host,
+	}
+
+	if u.Scheme == "" {
+		u.Scheme = "http"
+	}
+
+	switch t.Form {
+	case AbsoluteForm:
+		if host != "" && !sameAuthority(host, t.Authority, t.Scheme) {
+			return nil, ErrRequestHost
+		}
+		u.Scheme = t.Scheme
+		u.Host = t.Authority
+
+	case AuthorityForm:
+		if host != "" && !sameAuthority(host, t.Authority, u.Scheme) {
+			return nil, ErrRequestHost
+		}
+		u.Host = t.Authority
+		return u, nil
+
+	default:
+		if host == "" {
+			return nil, ErrRequestNoHost
+		}
+		if t.Form == AsteriskForm {
+			return u, nil
+		}
+	}
+
+	// RFC 9112 section 3.2.2: an absolute-form target without a path
+	// refers to "/".
+	path := t.Path
+	if path == "" {
+		path = "/"
+	}
+
+	p, err := url.ParseRequestURI(path)
+	if err != nil {
+		return nil, err
+	}
+
+	u.Path = p.Path
+	u.RawPath = p.RawPath
+	u.RawQuery = t.RawQuery
 
 	return u, nil
 }
 
+// host returns the value of the request's "Host" field, or an empty string if
+// it has none.
+func (r *Request) host() (string, error) {
+	i := r.Fields.Index("Host", 0)
+	if i < 0 {
+		return "", nil
+	}
+
+	// RFC 9112 section 3.2: more than one "Host" field is an error.
+	if r.Fields.Index("Host", i+1) >= 0 {
+		return "", ErrRequestHost
+	}
+
+	host := strtrim(r.Fields[i].Value)
+	if strings.ContainsAny(host, " \t/?#@") {
+		return "", ErrRequestHost
+	}
+
+	return host, nil
+}
+
+// sameAuthority compares two authorities case-insensitively, treating an
+// omitted port as the default port of the scheme.
+func sameAuthority(a, b, scheme string) bool {
+	var port string
+
+	switch scheme {
+	case "http":
+		port = ":80"
+	case "https":
+		port = ":443"
+	}
+
+	if port != "" {
+		a = strings.TrimSuffix(a, port)
+		b = strings.TrimSuffix(b, port)
+	}
+
+	return strcaseeq(a, b)
+}
+
 // WriteRequestHeader writes an HTTP request header to w.
-func WriteRequestHeader(w xo.Writer, req *Request) error {
+func WriteRequestHeader(w xo.Writer, req *Request) (err error) {
+	if t := traceOf(w); t != nil {
+		defer func() { t.wroteHeader(err) }()
+	}
+
 	buf, err := w.Reserve(len(req.Method) + len(req.URI) + 10 + 20 + 20)
 	if err != nil {
 		return err
@@ -104,54 +206,115 @@ func WriteRequestHeader(w xo.Writer, req *Request) error {
 func ReadRequestHeader(r xo.Reader) (*Request, error) {
 	var req = new(Request)
 
-	// Fetch the whole Request-Line.
-	buf, err := xo.PeekTo(r, '\n', 0)
-	if err != nil {
+	if err := readRequestHeader(r, req, nil); err != nil {
 		return nil, err
 	}
 
-	method, rest := strtok(buf, ' ')
-	if len(method) == 0 || rest == nil {
-		return nil, ErrRequestHeader
+	return req, nil
+}
+
+// ReadRequestHeaderInto works like ReadRequestHeader, but reads the header
+// into an existing Request after resetting it. The capacity of its Fields
+// slice is reused, and all strings are backed by a single buffer owned by
+// req, which means they're only valid until req is reset or reused.
+//
+// Copies of req share its strings, which are invalidated when req is reset or
+// reused. Resetting or reusing a copy, on the other hand, never affects the
+// original.
+func ReadRequestHeaderInto(r xo.Reader, req *Request) error {
+	req.Reset()
+	return readRequestHeader(r, req, &req.arena)
+}
+
+// Reset clears all of the request's properties, while retaining the capacity
+// of its Fields slice and internal buffers. Combined with a sync.Pool and
+// ReadRequestHeaderInto, this allows Request values to be recycled.
+//
+// Buffers are only retained once the request has been reset before. The
+// first Reset of a request, or of a copy of one, starts from scratch, as the
+// buffers may be shared with another request.
+func (r *Request) Reset() {
+	fields := r.Fields[:0]
+
+	// Copies share their Fields slice with the original.
+	if !r.arena.reset() {
+		fields = nil
 	}
 
-	uri, rest := strtok(rest, ' ')
-	if len(uri) == 0 || rest == nil {
-		return nil, ErrRequestHeader
+	*r = Request{
+		Fields: fields,
+		arena:  r.arena,
 	}
+}
 
-	// Trim trailing CRLF/LF.
-	if len(rest) < 2 {
-		return nil, ErrRequestHeader
-	} else if rest[len(rest)-2] == '\r' {
-		rest = rest[:len(rest)-2]
-	} else {
-		rest = rest[:len(rest)-1]
+func readRequestHeader(r xo.Reader, req *Request, a *arena) (err error) {
+	if t := traceOf(r); t != nil {
+		if err := t.awaitHeader(r); err != nil {
+			return err
+		}
+		defer func() { t.readHeader(err) }()
 	}
 
-	req.Major, req.Minor, err = parseHTTPVersion(rest)
+	// Fetch the whole Request-Line.
+	buf, err := xo.PeekTo(r, '\n', 0)
 	if err != nil {
-		return nil, ErrRequestVersion
+		return err
 	}
 
-	req.Method = stringify(method)
-	req.URI = string(uri)
+	method, uri, major, minor, err := parseRequestLine(buf)
+	if err != nil {
+		return err
+	}
+
+	req.Method = a.stringify(method)
+	req.URI = a.string(uri)
+	req.Major = major
+	req.Minor = minor
 
 	// Consume the Request-Line.
 	if err := r.Consume(len(buf)); err != nil {
-		return nil, err
+		return err
 	}
 
 	// Read header fields.
-	req.Fields, err = readHeader(r)
+	req.Fields, err = readHeader(r, req.Fields, a)
 	if err != nil {
 		if err == errMalformedHeader {
 			err = ErrRequestHeader
 		}
-		return nil, err
+		return err
 	}
 
-	return req, nil
+	return nil
+}
+
+// parseRequestLine parses a Request-Line, including its line break.
+func parseRequestLine(buf []byte) (method, uri []byte, major, minor int, err error) {
+	method, rest := strtok(buf, ' ')
+	if len(method) == 0 || rest == nil {
+		return nil, nil, 0, 0, ErrRequestHeader
+	}
+
+	uri, rest = strtok(rest, ' ')
+	if len(uri) == 0 || rest == nil {
+		return nil, nil, 0, 0, ErrRequestHeader
+	}
+
+	// Trim trailing CRLF/LF.
+	if len(rest) < 2 {
+		return nil, nil, 0, 0, ErrRequestHeader
+	} else if rest[len(rest)-2] == '\r' {
+		rest = rest[:len(rest)-2]
+	} else {
+		rest = rest[:len(rest)-1]
+	}
+
+	major, minor, err = parseHTTPVersion(rest)
+	if err != nil {
+		return nil, nil, 0, 0, ErrRequestVersion
+	}
+
+	return method, uri, major, minor, nil
 }
 
 var httpSlashOneDot = []byte{'H', 'T', 'T', 'P', '/', '1', '.'}
@@ -0,0 +1,103 @@
+package heat
+
+import (
+	"bufio"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const pipelinedRequests = "GET /a HTTP/1.1\r\nHost: example.com\r\nX-Long: " + "aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa" + "\r\n\r\n" +
+	"POST /b HTTP/1.0\r\nHost: example.org\r\nX-Other: b\r\n\r\n"
+
+func TestReadRequestHeaderInto(t *testing.T) {
+	r := NewBufioReader(bufio.NewReader(strings.NewReader(pipelinedRequests)))
+
+	var req Request
+
+	if err := ReadRequestHeaderInto(r, &req); err != nil {
+		t.Fatalf("ReadRequestHeaderInto #1: %v", err)
+	}
+
+	// A copy of the first request must survive the original being reused.
+	first := req
+	want := Request{
+		Method: "GET",
+		URI:    "/a",
+		Major:  1,
+		Minor:  1,
+		Fields: Fields{{"Host", "example.com"}, {"X-Long", strings.Repeat("a", 40)}},
+	}
+
+	if err := ReadRequestHeaderInto(r, &first); err != nil {
+		t.Fatalf("ReadRequestHeaderInto (copy): %v", err)
+	}
+
+	if req.Method != want.Method || req.URI != want.URI || !reflect.DeepEqual(req.Fields, want.Fields) {
+		t.Errorf("original after reusing copy:")
+		t.Errorf("  got  %s %s %q", req.Method, req.URI, req.Fields)
+		t.Errorf("  want %s %s %q", want.Method, want.URI, want.Fields)
+	}
+
+	if first.Method != "POST" || first.URI != "/b" || first.Minor != 0 || !reflect.DeepEqual(first.Fields, Fields{{"Host", "example.org"}, {"X-Other", "b"}}) {
+		t.Errorf("copy:")
+		t.Errorf("  got  %s %s HTTP/%d.%d %q", first.Method, first.URI, first.Major, first.Minor, first.Fields)
+	}
+}
+
+func TestReadRequestHeaderIntoAllocs(t *testing.T) {
+	var sr strings.Reader
+	var br = bufio.NewReader(&sr)
+	var r = NewBufioReader(br)
+	var req Request
+
+	read := func() {
+		sr.Reset(pipelinedRequests)
+		br.Reset(&sr)
+
+		if err := ReadRequestHeaderInto(r, &req); err != nil {
+			t.Fatalf("ReadRequestHeaderInto: %v", err)
+		}
+	}
+
+	// Warm up the request's buffers.
+	read()
+	read()
+
+	if n := testing.AllocsPerRun(100, read); n != 0 {
+		t.Errorf("ReadRequestHeaderInto: got %v allocations, want 0", n)
+	}
+}
+
+func TestReadResponseHeaderInto(t *testing.T) {
+	input := "HTTP/1.1 200 OK\r\nX-A: first\r\n\r\n" +
+		"HTTP/1.1 404 Not Found\r\nX-B: second\r\n\r\n"
+
+	r := NewBufioReader(bufio.NewReader(strings.NewReader(input)))
+
+	var resp Response
+
+	if err := ReadResponseHeaderInto(r, &resp); err != nil {
+		t.Fatalf("ReadResponseHeaderInto #1: %v", err)
+	}
+
+	saved := resp
+
+	if err := ReadResponseHeaderInto(r, &saved); err != nil {
+		t.Fatalf("ReadResponseHeaderInto (copy): %v", err)
+	}
+
+	if resp.Status != 200 || resp.Reason != "OK" || !reflect.DeepEqual(resp.Fields, Fields{{"X-A", "first"}}) {
+		t.Errorf("original after reusing copy: got %d %s %q", resp.Status, resp.Reason, resp.Fields)
+	}
+
+	if saved.Status != 404 || saved.Reason != "Not Found" || !reflect.DeepEqual(saved.Fields, Fields{{"X-B", "second"}}) {
+		t.Errorf("copy: got %d %s %q", saved.Status, saved.Reason, saved.Fields)
+	}
+
+	// Resetting keeps the capacity of the Fields slice.
+	saved.Reset()
+	if saved.Status != 0 || len(saved.Fields) != 0 || cap(saved.Fields) == 0 {
+		t.Errorf("Reset: got status %d, %d fields with capacity %d", saved.Status, len(saved.Fields), cap(saved.Fields))
+	}
+}
@@ -22,6 +22,9 @@ type Response struct {
 
 	// Optional message body.
 	Body io.ReadCloser
+
+	// Backing storage for strings, used by ReadResponseHeaderInto.
+	arena arena
 }
 
 // The NewResponse function constructs a minimal Response instance given
@@ -36,7 +39,11 @@ func NewResponse(status int, reason string) *Response {
 }
 
 // WriteResponseHeader writes an HTTP response header to w.
-func WriteResponseHeader(w xo.Writer, resp *Response) error {
+func WriteResponseHeader(w xo.Writer, resp *Response) (err error) {
+	if t := traceOf(w); t != nil {
+		defer func() { t.wroteHeader(err) }()
+	}
+
 	buf, err := w.Reserve(len(resp.Reason) + 10 + 20 + 20 + 20)
 	if err != nil {
 		return err
@@ -63,57 +70,119 @@ func WriteResponseHeader(w xo.Writer, resp *Response) error {
 func ReadResponseHeader(r xo.Reader) (*Response, error) {
 	var resp = new(Response)
 
-	// Fetch the Status-Line.
-	buf, err := xo.PeekTo(r, '\n', 0)
-	if err != nil {
+	if err := readResponseHeader(r, resp, nil); err != nil {
 		return nil, err
 	}
 
-	version, rest := strtok(buf, ' ')
-	if len(version) == 0 || rest == nil {
-		return nil, ErrResponseHeader
+	return resp, nil
+}
+
+// ReadResponseHeaderInto works like ReadResponseHeader, but reads the header
+// into an existing Response after resetting it. The capacity of its Fields
+// slice is reused, and all strings are backed by a single buffer owned by
+// resp, which means they're only valid until resp is reset or reused.
+//
+// Copies of resp share its strings, which are invalidated when resp is reset or
+// reused. Resetting or reusing a copy, on the other hand, never affects the
+// original.
+func ReadResponseHeaderInto(r xo.Reader, resp *Response) error {
+	resp.Reset()
+	return readResponseHeader(r, resp, &resp.arena)
+}
+
+// Reset clears all of the response's properties, while retaining the
+// capacity of its Fields slice and internal buffers. Combined with
+// a sync.Pool and ReadResponseHeaderInto, this allows Response values to be
+// recycled.
+//
+// Buffers are only retained once the response has been reset before. The
+// first Reset of a response, or of a copy of one, starts from scratch, as the
+// buffers may be shared with another response.
+func (resp *Response) Reset() {
+	fields := resp.Fields[:0]
+
+	// Copies share their Fields slice with the original.
+	if !resp.arena.reset() {
+		fields = nil
 	}
 
-	resp.Major, resp.Minor, err = parseHTTPVersion(version)
-	if err != nil {
-		return nil, ErrResponseVersion
+	*resp = Response{
+		Fields: fields,
+		arena:  resp.arena,
 	}
+}
 
-	status, rest := strtok(rest, ' ')
-	if len(status) == 0 || rest == nil {
-		return nil, ErrResponseHeader
+func readResponseHeader(r xo.Reader, resp *Response, a *arena) (err error) {
+	if t := traceOf(r); t != nil {
+		if err := t.awaitHeader(r); err != nil {
+			return err
+		}
+		defer func() { t.readHeader(err) }()
 	}
 
-	code, ok := atoi(status)
-	if !ok || code > maxInt {
-		return nil, ErrResponseHeader
+	// Fetch the Status-Line.
+	buf, err := xo.PeekTo(r, '\n', 0)
+	if err != nil {
+		return err
 	}
 
-	// Trim trailing CRLF/LF.
-	if len(rest) < 2 {
-		return nil, ErrResponseHeader
-	} else if rest[len(rest)-2] == '\r' {
-		rest = rest[:len(rest)-2]
-	} else {
-		rest = rest[:len(rest)-1]
+	major, minor, status, reason, err := parseStatusLine(buf)
+	if err != nil {
+		return err
 	}
 
-	resp.Status = int(code)
-	resp.Reason = stringify(rest)
+	resp.Major = major
+	resp.Minor = minor
+	resp.Status = status
+	resp.Reason = a.stringify(reason)
 
 	// Consume the Status-Line.
 	if err := r.Consume(len(buf)); err != nil {
-		return nil, err
+		return err
 	}
 
 	// Read header fields.
-	resp.Fields, err = readHeader(r)
+	resp.Fields, err = readHeader(r, resp.Fields, a)
 	if err != nil {
 		if err == errMalformedHeader {
 			err = ErrResponseHeader
 		}
-		return nil, err
+		return err
 	}
 
-	return resp, nil
+	return nil
+}
+
+// parseStatusLine parses a Status-Line, including its line break.
+func parseStatusLine(buf []byte) (major, minor, status int, reason []byte, err error) {
+	version, rest := strtok(buf, ' ')
+	if len(version) == 0 || rest == nil {
+		return 0, 0, 0, nil, ErrResponseHeader
+	}
+
+	major, minor, err = parseHTTPVersion(version)
+	if err != nil {
+		return 0, 0, 0, nil, ErrResponseVersion
+	}
+
+	code, rest := strtok(rest, ' ')
+	if len(code) == 0 || rest == nil {
+		return 0, 0, 0, nil, ErrResponseHeader
+	}
+
+	n, ok := atoi(code)
+	if !ok || n > maxInt {
+		return 0, 0, 0, nil, ErrResponseHeader
+	}
+
+	// Trim trailing CRLF/LF.
+	if len(rest) < 2 {
+		return 0, 0, 0, nil, ErrResponseHeader
+	} else if rest[len(rest)-2] == '\r' {
+		rest = rest[:len(rest)-2]
+	} else {
+		rest = rest[:len(rest)-1]
+	}
+
+	return major, minor, int(n), rest, nil
 }
@@ -80,7 +80,7 @@ func digits10(x uint64) int {
 }
 
 func atoi(buf []byte) (int64, bool) {
-	const cutoff = (1<<64-1)/10 + 1
+	const cutoff = (1<<63-1)/10 + 1
 
 	var x int64
 
@@ -42,6 +42,9 @@ var atoiTests = []struct {
 	{"-1", 0, false},
 	{"9223372036854775807", 9223372036854775807, true},
 	{"9223372036854775808", 0, false},
+	{"9999999999999999999", 0, false},
+	{"18446744073709551615", 0, false},
+	{"18446744073709551616", 0, false},
 }
 
 func TestAtoi(t *testing.T) {
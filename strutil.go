@@ -2,6 +2,7 @@ package heat
 
 import (
 	"bytes"
+	"strings"
 )
 
 var lowcase = [256]byte{
@@ -66,6 +67,16 @@ func strtrim(s string) string {
 	return s[l : r+1]
 }
 
+// istoken reports whether c is a "tchar", i.e. valid in a token as defined
+// by RFC 7230 section 3.2.6.
+func istoken(c byte) bool {
+	switch {
+	case 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z', '0' <= c && c <= '9':
+		return true
+	}
+	return strings.IndexByte("!#$%&'*+-.^_`|~", c) >= 0
+}
+
 func strtok(buf []byte, sep byte) (tok, rest []byte) {
 	if i := bytes.IndexByte(buf, sep); i >= 0 {
 		return buf[:i], buf[i+1:]
@@ -74,6 +85,92 @@ func strtok(buf []byte, sep byte) (tok, rest []byte) {
 	}
 }
 
+// splitList invokes fn with each non-empty element of a comma-separated list,
+// with surrounding whitespace removed. Commas inside quoted-strings are not
+// treated as separators.
+func splitList(s string, fn func(s string) bool) {
+	var quoted bool
+	var start int
+
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == '\\' && quoted:
+			i++
+		case c == '"':
+			quoted = !quoted
+		case c == ',' && !quoted:
+			if e := strings.Trim(s[start:i], " \t"); e != "" {
+				if !fn(e) {
+					return
+				}
+			}
+			start = i + 1
+		}
+	}
+
+	if e := strings.Trim(s[start:], " \t"); e != "" {
+		fn(e)
+	}
+}
+
+// unquote strips the surrounding double quotes and any backslash escapes from
+// a quoted-string. Other values are returned as-is.
+func unquote(s string) string {
+	if len(s) < 2 || s[0] != '"' || s[len(s)-1] != '"' {
+		return s
+	}
+
+	s = s[1 : len(s)-1]
+	if strings.IndexByte(s, '\\') < 0 {
+		return s
+	}
+
+	buf := make([]byte, 0, len(s))
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+1 < len(s) {
+			i++
+		}
+		buf = append(buf, s[i])
+	}
+
+	return string(buf)
+}
+
+// quote returns s as a quoted-string, escaping double quotes and
+// backslashes.
+func quote(s string) string {
+	buf := make([]byte, 0, len(s)+2)
+	buf = append(buf, '"')
+
+	for i := 0; i < len(s); i++ {
+		if c := s[i]; c == '"' || c == '\\' {
+			buf = append(buf, '\\')
+		}
+		buf = append(buf, s[i])
+	}
+
+	return string(append(buf, '"'))
+}
+
+// quoteIfNeeded returns s verbatim if it's a valid token, otherwise as
+// a quoted-string.
+func quoteIfNeeded(s string) string {
+	if s == "" || !isToken(s) {
+		return quote(s)
+	}
+	return s
+}
+
+// isToken reports whether s consists solely of tchars.
+func isToken(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if !istoken(s[i]) {
+			return false
+		}
+	}
+	return true
+}
+
 var common = make(map[string]string)
 
 func init() {
@@ -0,0 +1,90 @@
+package heat
+
+import (
+	"net/url"
+	"strings"
+)
+
+// The TargetForm type enumerates the four forms of request-target defined in
+// RFC 9112 section 3.2.
+type TargetForm int
+
+const (
+	OriginForm    TargetForm = iota // "/where?q=now"
+	AbsoluteForm                    // "http://www.example.org/pub/WWW/TheProject.html"
+	AuthorityForm                   // "www.example.com:80" (CONNECT only)
+	AsteriskForm                    // "*" (OPTIONS only)
+)
+
+// The Target struct represents a parsed request-target.
+type Target struct {
+	Form TargetForm
+
+	// Scheme is only set for absolute-form targets, and Authority for
+	// absolute-form and authority-form targets.
+	Scheme    string
+	Authority string
+
+	// Path and query of origin-form and absolute-form targets. The path is
+	// kept in its raw, escaped form.
+	Path     string
+	RawQuery string
+}
+
+// ParseTarget parses a request-target. The method is needed to recognize
+// authority-form targets, which are only used with CONNECT.
+func ParseTarget(method, uri string) (*Target, error) {
+	switch {
+	case method == "CONNECT":
+		// The authority-form must consist of a host and port, and
+		// nothing else.
+		if uri == "" || strings.ContainsAny(uri, "/?#@") || strings.LastIndexByte(uri, ':') < 0 {
+			return nil, ErrRequestTarget
+		}
+
+		if _, err := url.Parse("//" + uri); err != nil {
+			return nil, ErrRequestTarget
+		}
+
+		return &Target{Form: AuthorityForm, Authority: uri}, nil
+
+	case uri == "*":
+		if method != "OPTIONS" {
+			return nil, ErrRequestTarget
+		}
+
+		return &Target{Form: AsteriskForm}, nil
+
+	case strings.HasPrefix(uri, "/"):
+		// Reject network-path references such as "//example.com/".
+		if strings.HasPrefix(uri, "//") || strings.IndexByte(uri, '#') >= 0 {
+			return nil, ErrRequestTarget
+		}
+
+		path, query := uri, ""
+		if i := strings.IndexByte(uri, '?'); i >= 0 {
+			path, query = uri[:i], uri[i+1:]
+		}
+
+		return &Target{Form: OriginForm, Path: path, RawQuery: query}, nil
+
+	default:
+		u, err := url.Parse(uri)
+		if err != nil || u.Scheme == "" || u.Host == "" || u.Opaque != "" || u.User != nil || u.Fragment != "" {
+			return nil, ErrRequestTarget
+		}
+
+		return &Target{
+			Form:      AbsoluteForm,
+			Scheme:    strings.ToLower(u.Scheme),
+			Authority: u.Host,
+			Path:      u.EscapedPath(),
+			RawQuery:  u.RawQuery,
+		}, nil
+	}
+}
+
+// Target parses the request's Request-URI.
+func (r *Request) Target() (*Target, error) {
+	return ParseTarget(r.Method, r.URI)
+}
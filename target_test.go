@@ -0,0 +1,74 @@
+package heat
+
+import (
+	"reflect"
+	"testing"
+)
+
+var parseTargetTests = []struct {
+	method, uri string
+	out         *Target
+	err         error
+}{
+	{"GET", "/where?q=now", &Target{Form: OriginForm, Path: "/where", RawQuery: "q=now"}, nil},
+	{"GET", "http://www.example.org/pub/WWW/TheProject.html", &Target{Form: AbsoluteForm, Scheme: "http", Authority: "www.example.org", Path: "/pub/WWW/TheProject.html"}, nil},
+	{"GET", "HTTP://example.org", &Target{Form: AbsoluteForm, Scheme: "http", Authority: "example.org"}, nil},
+	{"CONNECT", "www.example.com:80", &Target{Form: AuthorityForm, Authority: "www.example.com:80"}, nil},
+	{"OPTIONS", "*", &Target{Form: AsteriskForm}, nil},
+	{"GET", "*", nil, ErrRequestTarget},
+	{"CONNECT", "/", nil, ErrRequestTarget},
+	{"CONNECT", "example.com", nil, ErrRequestTarget},
+	{"GET", "//example.com/", nil, ErrRequestTarget},
+	{"GET", "example.com", nil, ErrRequestTarget},
+	{"GET", "http://user@example.com/", nil, ErrRequestTarget},
+}
+
+func TestParseTarget(t *testing.T) {
+	for _, test := range parseTargetTests {
+		out, err := ParseTarget(test.method, test.uri)
+		if !reflect.DeepEqual(out, test.out) || err != test.err {
+			t.Errorf("ParseTarget(%q, %q):", test.method, test.uri)
+			t.Errorf("  got  %+v, %v", out, err)
+			t.Errorf("  want %+v, %v", test.out, test.err)
+		}
+	}
+}
+
+var resolveURLTests = []struct {
+	method, uri, scheme string
+	fields              Fields
+	out                 string
+	err                 error
+}{
+	{"GET", "/a%2Fb?x=1", "https", Fields{{"Host", "example.com"}}, "https://example.com/a%2Fb?x=1", nil},
+	{"GET", "/", "", Fields{{"Host", "example.com"}}, "http://example.com/", nil},
+	{"GET", "http://example.com/x", "https", Fields{{"Host", "EXAMPLE.com:80"}}, "http://example.com/x", nil},
+	{"GET", "http://example.com/x", "", nil, "http://example.com/x", nil},
+	{"GET", "http://example.com", "", nil, "http://example.com/", nil},
+	{"GET", "HTTP://example.com?x=1", "", Fields{{"Host", "example.com"}}, "http://example.com/?x=1", nil},
+	{"CONNECT", "example.com:443", "http", Fields{{"Host", "example.com:443"}}, "http://example.com:443", nil},
+	{"OPTIONS", "*", "http", Fields{{"Host", "example.com"}}, "http://example.com", nil},
+	{"GET", "/", "http", nil, "", ErrRequestNoHost},
+	{"GET", "/", "http", Fields{{"Host", "a"}, {"Host", "b"}}, "", ErrRequestHost},
+	{"GET", "http://example.com/x", "http", Fields{{"Host", "example.org"}}, "", ErrRequestHost},
+	{"CONNECT", "example.com:443", "http", Fields{{"Host", "example.org:443"}}, "", ErrRequestHost},
+}
+
+func TestResolveURL(t *testing.T) {
+	for _, test := range resolveURLTests {
+		req := &Request{Method: test.method, URI: test.uri, Scheme: test.scheme, Fields: test.fields}
+
+		var out string
+
+		u, err := req.ResolveURL()
+		if u != nil {
+			out = u.String()
+		}
+
+		if out != test.out || err != test.err {
+			t.Errorf("ResolveURL(%s %s, %q, %q):", test.method, test.uri, test.scheme, test.fields)
+			t.Errorf("  got  %q, %v", out, err)
+			t.Errorf("  want %q, %v", test.out, test.err)
+		}
+	}
+}
@@ -0,0 +1,134 @@
+package heat
+
+import (
+	"io"
+	"sync"
+	"time"
+
+	"github.com/erkl/xo"
+)
+
+// The Limiter type is a token bucket, limiting throughput to a number of
+// bytes per second while allowing bursts of up to a certain size. It's safe
+// for concurrent use, so a single Limiter can cap the combined bandwidth of
+// any number of connections.
+type Limiter struct {
+	rate  float64
+	burst int
+
+	mu     sync.Mutex
+	tokens float64
+	last   time.Time
+}
+
+// NewLimiter constructs a Limiter allowing rate bytes per second, in bursts
+// of up to burst bytes. The burst size defaults to rate if not positive.
+func NewLimiter(rate, burst int) *Limiter {
+	if burst <= 0 {
+		burst = rate
+	}
+
+	return &Limiter{
+		rate:   float64(rate),
+		burst:  burst,
+		tokens: float64(burst),
+		last:   time.Now(),
+	}
+}
+
+// reserve takes n bytes worth of tokens from the bucket, returning how long
+// to wait before they may pass. The bucket goes into debt rather than making
+// callers queue, which spreads waits fairly between concurrent users.
+func (l *Limiter) reserve(n int) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+
+	l.tokens += now.Sub(l.last).Seconds() * l.rate
+	if l.tokens > float64(l.burst) {
+		l.tokens = float64(l.burst)
+	}
+
+	l.last = now
+	l.tokens -= float64(n)
+
+	if l.tokens >= 0 {
+		return 0
+	}
+
+	return time.Duration(-l.tokens / l.rate * float64(time.Second))
+}
+
+// ThrottleBody wraps a body, such as one returned by OpenBody, limiting the
+// rate at which it can be read to that allowed by all of limiters. Nil
+// limiters are ignored, which makes it convenient to combine an optional
+// per-connection limiter with an optional global one.
+func ThrottleBody(body io.Reader, limiters ...*Limiter) io.Reader {
+	var active []*Limiter
+	var max int
+
+	for _, l := range limiters {
+		if l == nil || l.rate <= 0 {
+			continue
+		}
+
+		if max == 0 || l.burst < max {
+			max = l.burst
+		}
+
+		active = append(active, l)
+	}
+
+	if len(active) == 0 {
+		return body
+	}
+
+	return &throttledReader{body, active, max}
+}
+
+// WriteBodyThrottled works like WriteBody, but limits the rate at which the
+// body is copied to that allowed by all of limiters. As with ThrottleBody,
+// nil limiters are ignored. The body is throttled before being framed, so
+// chunked encoding is unaffected, apart from chunks being no larger than the
+// smallest burst size.
+func WriteBodyThrottled(dst xo.Writer, src io.Reader, size BodySize, limiters ...*Limiter) error {
+	if src != nil {
+		src = ThrottleBody(src, limiters...)
+	}
+
+	return WriteBody(dst, src, size)
+}
+
+type throttledReader struct {
+	r        io.Reader
+	limiters []*Limiter
+
+	// Reads are capped at the smallest burst size, so that no single read
+	// exceeds what any bucket can hold.
+	max int
+}
+
+func (tr *throttledReader) Read(buf []byte) (int, error) {
+	if len(buf) > tr.max {
+		buf = buf[:tr.max]
+	}
+
+	n, err := tr.r.Read(buf)
+
+	if n > 0 {
+		var wait time.Duration
+
+		for _, l := range tr.limiters {
+			if d := l.reserve(n); d > wait {
+				wait = d
+			}
+		}
+
+		if wait > 0 {
+			time.Sleep(wait)
+		}
+	}
+
+	return n, err
+}
@@ -0,0 +1,61 @@
+package heat
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestThrottleBody(t *testing.T) {
+	global := NewLimiter(10000, 1000)
+
+	start := time.Now()
+
+	// The initial burst is free, after which the remaining 2000 bytes take
+	// at least 200ms.
+	n, err := io.Copy(io.Discard, ThrottleBody(strings.NewReader(strings.Repeat("x", 3000)), nil, global))
+	if n != 3000 || err != nil {
+		t.Fatalf("Copy: got %d, %v, want %d, %v", n, err, 3000, nil)
+	}
+
+	if d := time.Since(start); d < 190*time.Millisecond {
+		t.Errorf("Copy took %v, want at least 200ms", d)
+	}
+}
+
+func TestWriteBodyThrottled(t *testing.T) {
+	var buf bytes.Buffer
+
+	body := strings.Repeat("abcdefghij", 30)
+	w := NewBufioWriter(bufio.NewWriter(&buf))
+
+	if err := WriteBodyThrottled(w, strings.NewReader(body), Chunked, NewLimiter(1<<20, 100)); err != nil {
+		t.Fatalf("WriteBodyThrottled: %v", err)
+	}
+	if err := w.Flush(); err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+
+	// Chunks are capped at the burst size.
+	want := "64\r\n" + body[:100] + "\r\n" +
+		"64\r\n" + body[100:200] + "\r\n" +
+		"64\r\n" + body[200:] + "\r\n" +
+		"0\r\n\r\n"
+
+	if buf.String() != want {
+		t.Errorf("WriteBodyThrottled:")
+		t.Errorf("  got  %q", buf.String())
+		t.Errorf("  want %q", want)
+	}
+
+	r, _ := OpenBody(NewBufioReader(bufio.NewReader(&buf)), Chunked)
+
+	if out, err := io.ReadAll(r); string(out) != body || err != nil {
+		t.Errorf("OpenBody:")
+		t.Errorf("  got  %q, %v", out, err)
+		t.Errorf("  want %q, %v", body, nil)
+	}
+}
@@ -0,0 +1,153 @@
+package heat
+
+import (
+	"io"
+
+	"github.com/erkl/xo"
+)
+
+// The Trace struct holds hooks invoked at milestones while reading and
+// writing messages, for measuring where an exchange spends its time. Any of
+// the hooks may be nil.
+//
+// Hooks are only invoked by functions reading from a reader returned by
+// TraceReader, or writing to a writer returned by TraceWriter. They're
+// invoked synchronously, from the goroutine doing the reading or writing.
+type Trace struct {
+	// ConnectStart and ConnectDone are invoked around establishing a new
+	// connection, and GotConn once a connection (new or reused) has been
+	// obtained. Heat itself never invokes these; they're meant for clients
+	// managing connections, such as nethttp.Transport.
+	ConnectStart func(network, addr string)
+	ConnectDone  func(network, addr string, err error)
+	GotConn      func(reused bool)
+
+	// WroteHeader is invoked when a message header has been written, and
+	// WroteBody when WriteBody returns. Written data may still be buffered.
+	WroteHeader func(err error)
+	WroteBody   func(err error)
+
+	// WroteChunk is invoked for each chunk written to a chunked body,
+	// excluding the final, empty chunk.
+	WroteChunk func(size int)
+
+	// FirstByte is invoked when the first byte of a message header has
+	// arrived, and ReadHeader when the header has been read in full.
+	FirstByte  func()
+	ReadHeader func(err error)
+
+	// GotChunk is invoked as each chunk of a chunked body starts, excluding
+	// the final, empty chunk.
+	GotChunk func(size int64)
+
+	// ReadBody is invoked once a body opened with OpenBody has been read to
+	// the end, or reading it has failed. It's invoked at most once per body.
+	ReadBody func(err error)
+}
+
+// TraceReader returns a reader which reports to t as messages are read from
+// it. The returned reader must be passed to heat directly, as the trace can't
+// be found through other wrappers.
+func TraceReader(r xo.Reader, t *Trace) xo.Reader {
+	return &traceReader{r, t}
+}
+
+// TraceWriter returns a writer which reports to t as messages are written to
+// it. Like TraceReader, it must be passed to heat directly.
+func TraceWriter(w xo.Writer, t *Trace) xo.Writer {
+	return &traceWriter{w, t}
+}
+
+type traceReader struct {
+	xo.Reader
+	t *Trace
+}
+
+type traceWriter struct {
+	xo.Writer
+	t *Trace
+}
+
+// traceOf returns the trace associated with a reader or writer, if any.
+func traceOf(v interface{}) *Trace {
+	switch v := v.(type) {
+	case *traceReader:
+		return v.t
+	case *traceWriter:
+		return v.t
+	default:
+		return nil
+	}
+}
+
+// awaitHeader waits for the first byte of a message header.
+func (t *Trace) awaitHeader(r xo.Reader) error {
+	if t == nil {
+		return nil
+	}
+
+	if _, err := r.Peek(1); err != nil {
+		return err
+	}
+
+	if t.FirstByte != nil {
+		t.FirstByte()
+	}
+
+	return nil
+}
+
+func (t *Trace) readHeader(err error) {
+	if t != nil && t.ReadHeader != nil {
+		t.ReadHeader(err)
+	}
+}
+
+func (t *Trace) wroteHeader(err error) {
+	if t != nil && t.WroteHeader != nil {
+		t.WroteHeader(err)
+	}
+}
+
+func (t *Trace) wroteBody(err error) {
+	if t != nil && t.WroteBody != nil {
+		t.WroteBody(err)
+	}
+}
+
+func (t *Trace) wroteChunk(size int) {
+	if t != nil && t.WroteChunk != nil {
+		t.WroteChunk(size)
+	}
+}
+
+func (t *Trace) gotChunk(size int64) {
+	if t != nil && t.GotChunk != nil {
+		t.GotChunk(size)
+	}
+}
+
+// The traceBody type reports the end of a body being read.
+type traceBody struct {
+	r    io.Reader
+	t    *Trace
+	done bool
+}
+
+func (tb *traceBody) Read(buf []byte) (int, error) {
+	n, err := tb.r.Read(buf)
+
+	if err != nil && !tb.done {
+		tb.done = true
+
+		if tb.t.ReadBody != nil {
+			if err == io.EOF {
+				tb.t.ReadBody(nil)
+			} else {
+				tb.t.ReadBody(err)
+			}
+		}
+	}
+
+	return n, err
+}
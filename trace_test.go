@@ -0,0 +1,87 @@
+package heat
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func recordTrace(events *[]string) *Trace {
+	add := func(format string, args ...interface{}) {
+		*events = append(*events, fmt.Sprintf(format, args...))
+	}
+
+	return &Trace{
+		WroteHeader: func(err error) { add("wrote header %v", err) },
+		WroteBody:   func(err error) { add("wrote body %v", err) },
+		WroteChunk:  func(size int) { add("wrote chunk %d", size) },
+		FirstByte:   func() { add("first byte") },
+		ReadHeader:  func(err error) { add("read header %v", err) },
+		GotChunk:    func(size int64) { add("got chunk %d", size) },
+		ReadBody:    func(err error) { add("read body %v", err) },
+	}
+}
+
+func TestTrace(t *testing.T) {
+	var events []string
+	var buf bytes.Buffer
+
+	trace := recordTrace(&events)
+
+	req := &Request{
+		Method: "POST",
+		URI:    "/",
+		Major:  1,
+		Minor:  1,
+		Fields: Fields{
+			{"Host", "example.com"},
+			{"Transfer-Encoding", "chunked"},
+		},
+	}
+
+	w := TraceWriter(NewBufioWriter(bufio.NewWriter(&buf)), trace)
+
+	if err := WriteRequestHeader(w, req); err != nil {
+		t.Fatalf("WriteRequestHeader: %v", err)
+	}
+	if err := WriteBody(w, strings.NewReader("hello"), Chunked); err != nil {
+		t.Fatalf("WriteBody: %v", err)
+	}
+	if err := w.Flush(); err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+
+	r := TraceReader(NewBufioReader(bufio.NewReader(&buf)), trace)
+
+	if _, err := ReadRequestHeader(r); err != nil {
+		t.Fatalf("ReadRequestHeader: %v", err)
+	}
+
+	body, err := OpenBody(r, Chunked)
+	if err != nil {
+		t.Fatalf("OpenBody: %v", err)
+	}
+	if _, err := io.ReadAll(body); err != nil {
+		t.Fatalf("ReadAll: %v", err)
+	}
+
+	want := []string{
+		"wrote header <nil>",
+		"wrote chunk 5",
+		"wrote body <nil>",
+		"first byte",
+		"read header <nil>",
+		"got chunk 5",
+		"read body <nil>",
+	}
+
+	if !reflect.DeepEqual(events, want) {
+		t.Errorf("events:")
+		t.Errorf("  got  %q", events)
+		t.Errorf("  want %q", want)
+	}
+}
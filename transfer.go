@@ -72,7 +72,7 @@ func isChunkedTransfer(fields Fields) bool {
 
 func parseContentLength(fields Fields) (int64, error) {
 	var n int64 = -1
-	var i int
+	var i = -1
 
 	for {
 		// Find the next Content-Length field.
@@ -111,9 +111,13 @@ func parseContentLength(fields Fields) (int64, error) {
 	return n, nil
 }
 
-func WriteBody(dst xo.Writer, src io.Reader, size BodySize) error {
+func WriteBody(dst xo.Writer, src io.Reader, size BodySize) (err error) {
 	// TODO: Add support for the Multipart size.
 
+	if t := traceOf(dst); t != nil {
+		defer func() { t.wroteBody(err) }()
+	}
+
 	if size == 0 {
 		return nil
 	} else if src == nil && size > invalid {
@@ -125,7 +129,7 @@ func WriteBody(dst xo.Writer, src io.Reader, size BodySize) error {
 		_, err := io.CopyN(dst, src, int64(size))
 		return err
 	case size == Chunked:
-		cw := &chunkedWriter{dst, [18]byte{16: '\r', 17: '\n'}}
+		cw := NewChunkedWriter(dst)
 		if _, err := io.Copy(cw, src); err != nil {
 			return err
 		}
@@ -139,13 +143,30 @@ func WriteBody(dst xo.Writer, src io.Reader, size BodySize) error {
 }
 
 func OpenBody(src xo.Reader, size BodySize) (io.Reader, error) {
+	return OpenBodyTrailers(src, size, nil)
+}
+
+// OpenBodyTrailers works like OpenBody, but appends the trailers of chunked
+// bodies to trailers once the body has been read in full.
+func OpenBodyTrailers(src xo.Reader, size BodySize, trailers *Fields) (io.Reader, error) {
+	t := traceOf(src)
+
+	body, err := openBody(src, size, trailers, t)
+	if t != nil && body != nil {
+		body = &traceBody{r: body, t: t}
+	}
+
+	return body, err
+}
+
+func openBody(src xo.Reader, size BodySize, trailers *Fields, t *Trace) (io.Reader, error) {
 	switch {
 	case size == 0:
 		return nil, nil
 	case size > 0:
 		return &fixedReader{src, int64(size)}, nil
 	case size == Chunked:
-		return &chunkedReader{src, 0}, nil
+		return &chunkedReader{src, 0, trailers, t}, nil
 	case size == Unbounded:
 		return src, nil
 	default:
@@ -0,0 +1,40 @@
+package heat
+
+import (
+	"testing"
+)
+
+var bodySizeTests = []struct {
+	fields Fields
+	req    BodySize
+	resp   BodySize
+	err    error
+}{
+	{Fields{}, 0, Unbounded, nil},
+	{Fields{{"Content-Length", "12"}}, 12, 12, nil},
+	{Fields{{"Host", "a"}, {"Content-Length", "12"}}, 12, 12, nil},
+	{Fields{{"Content-Length", "12"}, {"content-length", " 12 "}}, 12, 12, nil},
+	{Fields{{"Content-Length", "12"}, {"Content-Length", "13"}}, 0, 0, ErrInvalidContentLength},
+	{Fields{{"Content-Length", "x"}}, 0, 0, ErrInvalidContentLength},
+	{Fields{{"Content-Length", "99999999999999999999"}}, 0, 0, ErrInvalidContentLength},
+	{Fields{{"Transfer-Encoding", "chunked"}, {"Content-Length", "12"}}, Chunked, Chunked, nil},
+	{Fields{{"Transfer-Encoding", "identity"}, {"Content-Length", "12"}}, 12, 12, nil},
+}
+
+func TestBodySize(t *testing.T) {
+	for _, test := range bodySizeTests {
+		req := &Request{Method: "POST", URI: "/", Fields: test.fields}
+		if n, err := RequestBodySize(req); n != test.req || err != test.err {
+			t.Errorf("RequestBodySize(%q):", test.fields)
+			t.Errorf("  got  %d, %v", n, err)
+			t.Errorf("  want %d, %v", test.req, test.err)
+		}
+
+		resp := &Response{Status: 200, Fields: test.fields}
+		if n, err := ResponseBodySize(resp, "GET"); n != test.resp || err != test.err {
+			t.Errorf("ResponseBodySize(%q):", test.fields)
+			t.Errorf("  got  %d, %v", n, err)
+			t.Errorf("  want %d, %v", test.resp, test.err)
+		}
+	}
+}
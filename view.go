@@ -0,0 +1,346 @@
+package heat
+
+import (
+	"bytes"
+	"unsafe"
+
+	"github.com/erkl/xo"
+)
+
+// The FieldView struct represents a header field whose name and value
+// reference an xo.Reader's buffered data.
+type FieldView struct {
+	Name, Value []byte
+}
+
+// Is performs a case-insensitive match on the field's name.
+func (f *FieldView) Is(name string) bool {
+	return len(f.Name) == len(name) && strcaseeq(bytestr(f.Name), name)
+}
+
+// The FieldsView type is a read-only counterpart of Fields, produced by
+// PeekRequestHeader and PeekResponseHeader. Its contents are only valid until
+// the underlying header is consumed.
+type FieldsView []FieldView
+
+// Get returns the value of the first field matching the specified name.
+// The second return value indicates whether a match was found.
+func (fs FieldsView) Get(name string) ([]byte, bool) {
+	if i := fs.Index(name, 0); i >= 0 {
+		return fs[i].Value, true
+	} else {
+		return nil, false
+	}
+}
+
+// Has returns true if the list contains a particular field.
+func (fs FieldsView) Has(name string) bool {
+	return fs.Index(name, 0) >= 0
+}
+
+// Index returns the index of the first field with the specified name,
+// starting the search at the index from.
+func (fs FieldsView) Index(name string, from int) int {
+	for i := from; i < len(fs); i++ {
+		if fs[i].Is(name) {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// Split parses a particular field value as a list of elements split over any
+// number of individual fields, using sep as the separator token. The provided
+// callback function will be invoked with each element, with any leading or
+// trailing whitespace removed.
+func (fs FieldsView) Split(name string, sep byte, fn func(b []byte) bool) {
+	for _, f := range fs {
+		if !f.Is(name) {
+			continue
+		}
+
+		v := f.Value
+
+		for {
+			i := bytes.IndexByte(v, sep)
+			if i < 0 {
+				break
+			}
+			if !fn(bytes.Trim(v[:i], " \t")) {
+				return
+			}
+			v = v[i+1:]
+		}
+
+		if !fn(bytes.Trim(v, " \t")) {
+			return
+		}
+	}
+}
+
+// Materialize copies the fields into a new, independently owned Fields list.
+func (fs FieldsView) Materialize() Fields {
+	if fs == nil {
+		return nil
+	}
+
+	fields := make(Fields, len(fs))
+	for i, f := range fs {
+		fields[i] = Field{stringify(f.Name), string(f.Value)}
+	}
+
+	return fields
+}
+
+// The RequestView struct represents a request header which has been parsed,
+// but not yet consumed, by PeekRequestHeader. All byte slices reference the
+// reader's buffered data, and are only valid until Consume is called.
+type RequestView struct {
+	// Method and Request-URI.
+	Method []byte
+	URI    []byte
+
+	// HTTP version.
+	Major int
+	Minor int
+
+	// Associated header fields.
+	Fields FieldsView
+
+	r    xo.Reader
+	size int
+}
+
+// Consume discards the header from the underlying reader, positioning it at
+// the start of the message body. The view must not be used afterwards.
+func (v *RequestView) Consume() error {
+	return v.r.Consume(v.size)
+}
+
+// Materialize copies the view into a new Request. The request has no body.
+func (v *RequestView) Materialize() *Request {
+	return &Request{
+		Method: stringify(v.Method),
+		URI:    string(v.URI),
+		Major:  v.Major,
+		Minor:  v.Minor,
+		Fields: v.Fields.Materialize(),
+	}
+}
+
+// The ResponseView struct represents a response header which has been
+// parsed, but not yet consumed, by PeekResponseHeader. All byte slices
+// reference the reader's buffered data, and are only valid until Consume is
+// called.
+type ResponseView struct {
+	// HTTP version.
+	Major int
+	Minor int
+
+	// Status code and reason phrase.
+	Status int
+	Reason []byte
+
+	// Associated header fields.
+	Fields FieldsView
+
+	r    xo.Reader
+	size int
+}
+
+// Consume discards the header from the underlying reader, positioning it at
+// the start of the message body. The view must not be used afterwards.
+func (v *ResponseView) Consume() error {
+	return v.r.Consume(v.size)
+}
+
+// Materialize copies the view into a new Response. The response has no body.
+func (v *ResponseView) Materialize() *Response {
+	return &Response{
+		Major:  v.Major,
+		Minor:  v.Minor,
+		Status: v.Status,
+		Reason: stringify(v.Reason),
+		Fields: v.Fields.Materialize(),
+	}
+}
+
+// PeekRequestHeader parses a request header into v without consuming it from
+// r. The capacity of v.Fields is reused. The reader's buffer is left
+// untouched, so a header may be peeked any number of times (and will still be
+// there after an error); call v.Consume before reading the message body.
+//
+// Headers larger than 1 MiB are rejected with ErrHeaderTooLarge.
+func PeekRequestHeader(r xo.Reader, v *RequestView) error {
+	buf, err := peekHeader(r)
+	if err != nil {
+		return err
+	}
+
+	line := buf[:bytes.IndexByte(buf, '\n')+1]
+
+	method, uri, major, minor, err := parseRequestLine(line)
+	if err != nil {
+		return err
+	}
+
+	fields, err := parseHeaderView(buf[len(line):], v.Fields[:0])
+	if err != nil {
+		if err == errMalformedHeader {
+			err = ErrRequestHeader
+		}
+		return err
+	}
+
+	*v = RequestView{
+		Method: method,
+		URI:    uri,
+		Major:  major,
+		Minor:  minor,
+		Fields: fields,
+		r:      r,
+		size:   len(buf),
+	}
+
+	return nil
+}
+
+// PeekResponseHeader parses a response header into v without consuming it
+// from r, and otherwise works like PeekRequestHeader.
+func PeekResponseHeader(r xo.Reader, v *ResponseView) error {
+	buf, err := peekHeader(r)
+	if err != nil {
+		return err
+	}
+
+	line := buf[:bytes.IndexByte(buf, '\n')+1]
+
+	major, minor, status, reason, err := parseStatusLine(line)
+	if err != nil {
+		return err
+	}
+
+	fields, err := parseHeaderView(buf[len(line):], v.Fields[:0])
+	if err != nil {
+		if err == errMalformedHeader {
+			err = ErrResponseHeader
+		}
+		return err
+	}
+
+	*v = ResponseView{
+		Major:  major,
+		Minor:  minor,
+		Status: status,
+		Reason: reason,
+		Fields: fields,
+		r:      r,
+		size:   len(buf),
+	}
+
+	return nil
+}
+
+// peekHeader peeks at a complete message header, from the start line up to
+// and including the blank line terminating it.
+func peekHeader(r xo.Reader) ([]byte, error) {
+	// Fetch the start line.
+	buf, err := peekLine(r, 0, defaultMaxHeaderSize)
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		off := len(buf)
+
+		if buf, err = peekLine(r, off, defaultMaxHeaderSize); err != nil {
+			return nil, err
+		}
+
+		if isBlankLine(buf[off:]) {
+			return buf, nil
+		}
+	}
+}
+
+// peekLine works like xo.PeekTo with '\n' as the delimiter, but gives up with
+// ErrHeaderTooLarge rather than peeking more than max bytes.
+func peekLine(r xo.Reader, off, max int) ([]byte, error) {
+	for n := off + 1; ; {
+		buf, err := r.Peek(n)
+
+		if i := bytes.IndexByte(buf[min(off, len(buf)):], '\n'); i >= 0 {
+			if off+i+1 > max {
+				return nil, ErrHeaderTooLarge
+			}
+			return buf[:off+i+1], nil
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		if len(buf) >= max {
+			return nil, ErrHeaderTooLarge
+		}
+
+		n = len(buf) + 1
+	}
+}
+
+// parseHeaderView parses the header fields in buf, which must end with
+// a blank line, appending them to fields.
+func parseHeaderView(buf []byte, fields FieldsView) (FieldsView, error) {
+	for {
+		end := bytes.IndexByte(buf, '\n') + 1
+		if isBlankLine(buf[:end]) {
+			return fields, nil
+		}
+
+		colon, err := fieldColon(buf[:end])
+		if err != nil {
+			return nil, err
+		}
+
+		// Include continuation lines.
+		for buf[end] == ' ' || buf[end] == '\t' {
+			end += bytes.IndexByte(buf[end:], '\n') + 1
+		}
+
+		name, value, err := viewField(buf[:end], colon)
+		if err != nil {
+			return nil, err
+		}
+
+		fields = append(fields, FieldView{name, value})
+		buf = buf[end:]
+	}
+}
+
+// viewField works like splitField, but leaves buf untouched. Values folded
+// over several lines are normalized in a copy.
+func viewField(buf []byte, colon int) (name, value []byte, err error) {
+	name = shrinkName(buf[:colon])
+	if len(name) == 0 {
+		return nil, nil, errMalformedHeader
+	}
+
+	value = buf[colon+1:]
+
+	if i := bytes.IndexByte(value, '\n'); i >= 0 && i < len(value)-1 {
+		value = shrinkValue(append([]byte(nil), value...))
+	} else {
+		value = bytes.TrimRight(bytes.TrimLeft(value, " "), " \t\r\n")
+	}
+
+	return name, value, nil
+}
+
+// bytestr returns a string sharing memory with b, for short-lived comparisons.
+func bytestr(b []byte) string {
+	if len(b) == 0 {
+		return ""
+	}
+	return unsafe.String(&b[0], len(b))
+}
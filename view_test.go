@@ -0,0 +1,175 @@
+package heat
+
+import (
+	"bufio"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+var parseHeaderViewTests = []struct {
+	in  string
+	out Fields
+	err error
+}{
+	{
+		"\r\n",
+		Fields{},
+		nil,
+	},
+	{
+		"Host: example.com\r\nAccept:  text/html \r\n\r\n",
+		Fields{{"Host", "example.com"}, {"Accept", "text/html"}},
+		nil,
+	},
+	{
+		"X-Long: a\r\n  b\r\n\tc\r\nX-Next: d\n\n",
+		Fields{{"X-Long", "a b c"}, {"X-Next", "d"}},
+		nil,
+	},
+	{
+		" Host: example.com\r\n\r\n",
+		nil,
+		errMalformedHeader,
+	},
+	{
+		"Host\r\n\r\n",
+		nil,
+		errMalformedHeader,
+	},
+}
+
+func TestParseHeaderView(t *testing.T) {
+	for _, test := range parseHeaderViewTests {
+		view, err := parseHeaderView([]byte(test.in), FieldsView{})
+		out := view.Materialize()
+		if err != test.err || !reflect.DeepEqual(out, test.out) {
+			t.Errorf("parseHeaderView(%q):", test.in)
+			t.Errorf("  got  %q, %v", out, err)
+			t.Errorf("  want %q, %v", test.out, test.err)
+		}
+	}
+}
+
+func TestFieldsViewSplit(t *testing.T) {
+	fs := FieldsView{
+		{[]byte("Accept-Encoding"), []byte("gzip, deflate")},
+		{[]byte("Host"), []byte("example.com")},
+		{[]byte("accept-encoding"), []byte(" br ,zstd,identity")},
+	}
+
+	var out []string
+	fs.Split("Accept-Encoding", ',', func(b []byte) bool {
+		out = append(out, string(b))
+		return true
+	})
+
+	want := []string{"gzip", "deflate", "br", "zstd", "identity"}
+	if !reflect.DeepEqual(out, want) {
+		t.Errorf("FieldsView.Split:")
+		t.Errorf("  got  %q", out)
+		t.Errorf("  want %q", want)
+	}
+
+	if v, ok := fs.Get("HOST"); !ok || string(v) != "example.com" {
+		t.Errorf("FieldsView.Get(%q):", "HOST")
+		t.Errorf("  got  %q, %v", v, ok)
+		t.Errorf("  want %q, %v", "example.com", true)
+	}
+}
+
+func TestPeekRequestHeader(t *testing.T) {
+	const in = "POST /a HTTP/1.1\r\nHost: example.com\r\nX-Long: a\r\n  b\r\nContent-Length: 4\r\n\r\nbody"
+
+	r := NewBufioReader(bufio.NewReader(strings.NewReader(in)))
+
+	var want = &Request{
+		Method: "POST",
+		URI:    "/a",
+		Major:  1,
+		Minor:  1,
+		Fields: Fields{{"Host", "example.com"}, {"X-Long", "a b"}, {"Content-Length", "4"}},
+	}
+
+	// Peeking twice must give the same result.
+	for i := 0; i < 2; i++ {
+		var v RequestView
+		if err := PeekRequestHeader(r, &v); err != nil {
+			t.Fatalf("PeekRequestHeader: %v", err)
+		}
+
+		if got := v.Materialize(); !reflect.DeepEqual(got, want) {
+			t.Errorf("PeekRequestHeader(%q):", in)
+			t.Errorf("  got  %+v", got)
+			t.Errorf("  want %+v", want)
+		}
+
+		if i == 1 {
+			if err := v.Consume(); err != nil {
+				t.Fatalf("Consume: %v", err)
+			}
+		}
+	}
+
+	if rest, err := io.ReadAll(r); err != nil || string(rest) != "body" {
+		t.Errorf("after Consume: got %q, %v, want %q", rest, err, "body")
+	}
+}
+
+func TestPeekResponseHeader(t *testing.T) {
+	const in = "HTTP/1.0 404 Not Found\r\nServer: heat\r\n\r\nHTTP/1.1 200 OK\r\n\r\n"
+
+	r := NewBufioReader(bufio.NewReader(strings.NewReader(in)))
+
+	var want = []*Response{
+		{Status: 404, Reason: "Not Found", Major: 1, Minor: 0, Fields: Fields{{"Server", "heat"}}},
+		{Status: 200, Reason: "OK", Major: 1, Minor: 1, Fields: Fields{}},
+	}
+
+	var v ResponseView
+
+	for _, want := range want {
+		if err := PeekResponseHeader(r, &v); err != nil {
+			t.Fatalf("PeekResponseHeader: %v", err)
+		}
+
+		if got := v.Materialize(); !reflect.DeepEqual(got, want) {
+			t.Errorf("PeekResponseHeader(%q):", in)
+			t.Errorf("  got  %+v", got)
+			t.Errorf("  want %+v", want)
+		}
+
+		if err := v.Consume(); err != nil {
+			t.Fatalf("Consume: %v", err)
+		}
+	}
+}
+
+func TestPeekHeaderErrors(t *testing.T) {
+	var tests = []struct {
+		in  string
+		err error
+	}{
+		{"GET / HTTP/1.1\r\n Host: a\r\n\r\n", ErrRequestHeader},
+		{"GET / HTTP/1.1\r\nHost: a\r\n", io.EOF},
+		{"GET / HTTP/1.1\r\nCookie: " + strings.Repeat("x", defaultMaxHeaderSize) + "\r\n\r\n", ErrHeaderTooLarge},
+		{"GET / HTTP/1.1\r\n" + strings.Repeat("X-Field: x\r\n", defaultMaxHeaderSize/12+1) + "\r\n", ErrHeaderTooLarge},
+	}
+
+	for _, test := range tests {
+		r := NewBufioReader(bufio.NewReaderSize(strings.NewReader(test.in), 2*defaultMaxHeaderSize))
+
+		var v RequestView
+		if err := PeekRequestHeader(r, &v); err != test.err {
+			t.Errorf("PeekRequestHeader(%.40q):", test.in)
+			t.Errorf("  got  %v", err)
+			t.Errorf("  want %v", test.err)
+		}
+
+		// The reader's buffer must be left as it was.
+		if rest, _ := io.ReadAll(r); string(rest) != test.in {
+			t.Errorf("PeekRequestHeader(%.40q): modified the input to %.40q", test.in, rest)
+		}
+	}
+}